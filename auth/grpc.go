@@ -0,0 +1,106 @@
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// PublicMethods 不需要Token的方法，以"/"结尾时按前缀匹配；健康检查和反射的调用方通常不带Token
+var PublicMethods = []string{
+	"/grpc.health.v1.Health/",
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+func public(method string) bool {
+	for _, m := range PublicMethods {
+		if m == method || (strings.HasSuffix(m, "/") && strings.HasPrefix(method, m)) {
+			return true
+		}
+	}
+	return false
+}
+
+func authenticateRPC(ctx context.Context, h *HMAC) (context.Context, error) {
+	var token string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get("authorization"); len(v) > 0 {
+			token = bearer(v[0])
+		}
+	}
+	ctx, err := authenticate(ctx, h, token)
+	if err != nil {
+		return ctx, status.Error(codes.Unauthenticated, err.Error())
+	}
+	return ctx, nil
+}
+
+// UnaryServerInterceptor 校验metadata里的authorization，失败返回Unauthenticated
+// otelgrpc的StatsHandler先于拦截器创建Span，enduser.id记在Server Span上；h为nil时不校验
+func UnaryServerInterceptor(h *HMAC) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if h == nil || public(info.FullMethod) {
+			return handler(ctx, req)
+		}
+		ctx, err := authenticateRPC(ctx, h)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+func StreamServerInterceptor(h *HMAC) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if h == nil || public(info.FullMethod) {
+			return handler(srv, ss)
+		}
+		ctx, err := authenticateRPC(ss.Context(), h)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// UnaryClientInterceptor 把ctx里调用方的Token原样转发给下游，下游看到的enduser.id仍是最初的调用方
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if p, ok := FromContext(ctx); ok && p.token != "" {
+			opts = append(opts, grpc.PerRPCCredentials(Token(p.token)))
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// Token 客户端用grpc.WithPerRPCCredentials(auth.Token(token))在每次调用时带上Token
+type Token string
+
+var _ credentials.PerRPCCredentials = Token("")
+
+func (t Token) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	if t == "" {
+		return nil, nil
+	}
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// RequireTransportSecurity Playground默认是明文连接，生产环境应配合TLS使用
+func (t Token) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Handler 校验Authorization: Bearer，失败返回401，成功后身份见FromContext
+// 放在otelhttp.NewHandler里面，enduser.id记到Server Span上；h为nil时不校验
+func Handler(h *HMAC, next http.Handler) http.Handler {
+	if h == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, err := authenticate(r.Context(), h, bearer(r.Header.Get("Authorization")))
+		if err != nil {
+			// RFC 6750：没有Token时不带error，Token无效或过期时为invalid_token
+			if errors.Is(err, ErrMissingToken) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="playground"`)
+			} else {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="playground", error="invalid_token"`)
+			}
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// Transport 给每个请求加上Authorization头，token为空时不加
+func Transport(base http.RoundTripper, token string) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if token == "" {
+		return base
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+token)
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
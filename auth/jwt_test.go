@@ -0,0 +1,50 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+// forge 用h的密钥签一个任意claims的Token
+func forge(t *testing.T, h *HMAC, alg string, c claims) string {
+	t.Helper()
+	hb, _ := json.Marshal(header{Alg: alg, Typ: "JWT"})
+	cb, err := json.Marshal(c)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signing := encoding.EncodeToString(hb) + "." + encoding.EncodeToString(cb)
+	return signing + "." + encoding.EncodeToString(h.mac(signing))
+}
+
+func TestVerify(t *testing.T) {
+	h := NewHMAC([]byte("secret"))
+	now := time.Now().Unix()
+
+	token, err := h.Sign("caiwenzhe", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p, err := h.Verify(token); err != nil || p.Subject != "caiwenzhe" {
+		t.Fatalf("Verify(signed) = %v, %v", p, err)
+	}
+
+	for _, tc := range []struct {
+		name  string
+		token string
+		want  error
+	}{
+		{"expired", forge(t, h, "HS256", claims{Sub: "a", Iat: now - 7200, Exp: now - 3600}), ErrExpiredToken},
+		{"no exp", forge(t, h, "HS256", claims{Sub: "a", Iat: now}), ErrInvalidToken},
+		{"no sub", forge(t, h, "HS256", claims{Iat: now, Exp: now + 3600}), ErrInvalidToken},
+		{"alg none", forge(t, h, "none", claims{Sub: "a", Iat: now, Exp: now + 3600}), ErrInvalidToken},
+		{"other secret", forge(t, NewHMAC([]byte("other")), "HS256", claims{Sub: "a", Iat: now, Exp: now + 3600}), ErrInvalidToken},
+		{"malformed", "a.b", ErrInvalidToken},
+	} {
+		if _, err := h.Verify(tc.token); !errors.Is(err, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, err, tc.want)
+		}
+	}
+}
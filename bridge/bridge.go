@@ -0,0 +1,100 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+// MappingEnv 覆盖默认映射表，格式为"header=baggage.key,..."，如"x-tenant-id=tenant.id,x-user=user-id"
+const MappingEnv = "PLAYGROUND_BAGGAGE_HEADERS"
+
+// Mapping 一个Header(gRPC里是metadata)与Baggage Key的对应关系
+type Mapping struct {
+	Header string // 不区分大小写
+	Key    string
+}
+
+// Table 收到请求时按表把Header提升为Baggage，发出请求时再把Baggage写回Header
+// 给不认识W3C baggage的老服务使用
+type Table []Mapping
+
+// DefaultTable 未设置MappingEnv时使用
+var DefaultTable = Table{
+	{Header: "X-Tenant-ID", Key: "tenant.id"},
+	{Header: "X-Request-ID", Key: "request.id"},
+}
+
+// Parse 解析MappingEnv的格式，Key必须是合法的Baggage Key
+func Parse(s string) (Table, error) {
+	var t Table
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		header, key, ok := strings.Cut(pair, "=")
+		header, key = strings.TrimSpace(header), strings.TrimSpace(key)
+		if !ok || header == "" || key == "" {
+			return nil, fmt.Errorf("invalid baggage mapping %q, want header=key", pair)
+		}
+		// NewMemberRaw不检查Key的格式，NewMember要求是W3C baggage的token，写回Header时才不会出错
+		if _, err := baggage.NewMember(key, ""); err != nil {
+			return nil, fmt.Errorf("invalid baggage mapping %q: %w", pair, err)
+		}
+		t = append(t, Mapping{Header: header, Key: key})
+	}
+	return t, nil
+}
+
+// FromEnv 未设置MappingEnv时返回DefaultTable
+func FromEnv() (Table, error) {
+	if s, ok := os.LookupEnv(MappingEnv); ok {
+		return Parse(s)
+	}
+	return DefaultTable, nil
+}
+
+// lift 按表把get取到的值加进ctx的Baggage，已有的成员(来自W3C baggage)优先
+func (t Table) lift(ctx context.Context, get func(string) string) context.Context {
+	bag := baggage.FromContext(ctx)
+	changed := false
+	for _, m := range t {
+		if bag.Member(m.Key).Key() != "" {
+			continue
+		}
+		v := get(m.Header)
+		if v == "" {
+			continue
+		}
+		member, err := baggage.NewMemberRaw(m.Key, v)
+		if err != nil {
+			continue
+		}
+		if b, err := bag.SetMember(member); err == nil {
+			bag, changed = b, true
+		}
+	}
+	if !changed {
+		return ctx
+	}
+	return baggage.ContextWithBaggage(ctx, bag)
+}
+
+// emit 按表把ctx里的Baggage成员交给set，has为true的Header(调用方自己设置的)不覆盖
+func (t Table) emit(ctx context.Context, has func(string) bool, set func(string, string)) {
+	bag := baggage.FromContext(ctx)
+	if bag.Len() == 0 {
+		return
+	}
+	for _, m := range t {
+		member := bag.Member(m.Key)
+		if member.Key() == "" || member.Value() == "" || has(m.Header) {
+			continue
+		}
+		set(m.Header, member.Value())
+	}
+}
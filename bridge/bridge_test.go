@@ -0,0 +1,65 @@
+package bridge
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestParse(t *testing.T) {
+	got, err := Parse(" x-tenant-id = tenant.id ,, X-User=user-id ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Table{{Header: "x-tenant-id", Key: "tenant.id"}, {Header: "X-User", Key: "user-id"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	if got, err := Parse(""); err != nil || len(got) != 0 {
+		t.Errorf("Parse(\"\") = %v, %v", got, err)
+	}
+	for _, s := range []string{
+		"x-tenant-id",           // 缺少=
+		"=tenant.id",            // 缺少header
+		"x-tenant-id=",          // 缺少key
+		"x-tenant-id=tenant id", // key里有空格
+		"x-a=a,x-b=b;c",         // 第二项不合法
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Parse(%q): want error", s)
+		}
+	}
+}
+
+func TestFromEnv(t *testing.T) {
+	t.Setenv(MappingEnv, "x-a=a")
+	got, err := FromEnv()
+	if err != nil || len(got) != 1 || got[0].Key != "a" {
+		t.Errorf("FromEnv() = %v, %v", got, err)
+	}
+	// 设置为空表示不映射任何Header，而不是回退到DefaultTable
+	t.Setenv(MappingEnv, "")
+	if got, err := FromEnv(); err != nil || len(got) != 0 {
+		t.Errorf("empty %s: FromEnv() = %v, %v", MappingEnv, got, err)
+	}
+}
+
+func TestLiftW3CWins(t *testing.T) {
+	member, _ := baggage.NewMemberRaw("tenant.id", "from-w3c")
+	bag, _ := baggage.New(member)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+
+	headers := map[string]string{"X-Tenant-ID": "from-header", "X-Request-ID": "req-1"}
+	ctx = DefaultTable.lift(ctx, func(k string) string { return headers[k] })
+
+	got := baggage.FromContext(ctx)
+	if v := got.Member("tenant.id").Value(); v != "from-w3c" {
+		t.Errorf("tenant.id = %q, W3C baggage should win", v)
+	}
+	if v := got.Member("request.id").Value(); v != "req-1" {
+		t.Errorf("request.id = %q, want the header value", v)
+	}
+}
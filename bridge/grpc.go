@@ -0,0 +1,70 @@
+package bridge
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// FromIncoming 把收到的metadata里的映射Key提升为Baggage
+func (t Table) FromIncoming(ctx context.Context) context.Context {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return t.lift(ctx, func(k string) string {
+		if v := md.Get(k); len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	})
+}
+
+// ToOutgoing 把ctx里的映射Baggage写进发出的metadata，metadata的key都是小写
+func (t Table) ToOutgoing(ctx context.Context) context.Context {
+	md, _ := metadata.FromOutgoingContext(ctx)
+	var kv []string
+	t.emit(ctx, func(k string) bool { return len(md.Get(k)) > 0 }, func(k, v string) {
+		kv = append(kv, strings.ToLower(k), v)
+	})
+	if len(kv) == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx, kv...)
+}
+
+// UnaryServerInterceptor otelgrpc的StatsHandler先取出W3C baggage，这里只补上缺少的成员
+func (t Table) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(t.FromIncoming(ctx), req)
+	}
+}
+
+func (t Table) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: t.FromIncoming(ss.Context())})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (t Table) UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(t.ToOutgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func (t Table) StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(t.ToOutgoing(ctx), desc, cc, method, opts...)
+	}
+}
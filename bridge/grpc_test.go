@@ -0,0 +1,63 @@
+package bridge
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestInterceptorsRoundTrip 客户端把Baggage写成metadata，服务端再提升回Baggage
+func TestInterceptorsRoundTrip(t *testing.T) {
+	var got context.Context
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		DefaultTable.UnaryServerInterceptor(),
+		func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			got = ctx
+			return handler(ctx, req)
+		},
+	))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(DefaultTable.UnaryClientInterceptor()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	member, _ := baggage.NewMemberRaw("tenant.id", "playground")
+	bag, _ := baggage.New(member)
+	ctx := baggage.ContextWithBaggage(context.Background(), bag)
+	// 调用方自己带的x-request-id不被Baggage覆盖
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "caller")
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	md, _ := metadata.FromIncomingContext(got)
+	if v := md.Get("x-tenant-id"); len(v) != 1 || v[0] != "playground" {
+		t.Errorf("x-tenant-id = %v", v)
+	}
+	// 只有Baggage的传播器没有配置，服务端的Baggage全部来自映射的metadata
+	b := baggage.FromContext(got)
+	if v := b.Member("tenant.id").Value(); v != "playground" {
+		t.Errorf("tenant.id = %q", v)
+	}
+	if v := b.Member("request.id").Value(); v != "caller" {
+		t.Errorf("request.id = %q", v)
+	}
+}
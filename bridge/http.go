@@ -0,0 +1,40 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+)
+
+// FromHeader 把h里的映射Header提升为Baggage
+// 手写埋点的Twin自己从Header取出W3C baggage，要在那之后调用，否则会被覆盖
+func (t Table) FromHeader(ctx context.Context, h http.Header) context.Context {
+	return t.lift(ctx, h.Get)
+}
+
+// ToHeader 把ctx里的映射Baggage写到h里
+func (t Table) ToHeader(ctx context.Context, h http.Header) {
+	t.emit(ctx, func(k string) bool { return h.Get(k) != "" }, h.Set)
+}
+
+// Handler 放在otelhttp.NewHandler里面，otelhttp取出的W3C baggage已经在ctx里
+func (t Table) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(t.FromHeader(r.Context(), r.Header)))
+	})
+}
+
+// Transport 发出请求时按表把Baggage写成Header
+func (t Table) Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		req = req.Clone(req.Context())
+		t.ToHeader(req.Context(), req.Header)
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,33 @@
+package bridge
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go.opentelemetry.io/otel/baggage"
+)
+
+func TestHeaderRoundTrip(t *testing.T) {
+	in := http.Header{}
+	in.Set("X-Tenant-ID", "playground")
+	ctx := DefaultTable.FromHeader(context.Background(), in)
+	if v := baggage.FromContext(ctx).Member("tenant.id").Value(); v != "playground" {
+		t.Fatalf("tenant.id = %q", v)
+	}
+
+	out := http.Header{}
+	// 调用方自己设置的Header不覆盖
+	out.Set("X-Request-ID", "caller")
+	member, _ := baggage.NewMemberRaw("request.id", "from-baggage")
+	bag := baggage.FromContext(ctx)
+	bag, _ = bag.SetMember(member)
+	DefaultTable.ToHeader(baggage.ContextWithBaggage(ctx, bag), out)
+
+	if v := out.Get("X-Tenant-ID"); v != "playground" {
+		t.Errorf("X-Tenant-ID = %q", v)
+	}
+	if v := out.Get("X-Request-ID"); v != "caller" {
+		t.Errorf("X-Request-ID = %q, caller's header was overwritten", v)
+	}
+}
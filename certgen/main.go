@@ -0,0 +1,39 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
+)
+
+// 生成本地演示用的一次性CA，以及同时可用于服务端和客户端的证书，24小时后过期
+// go run ./certgen -dir certs
+// go run ./grpc-twin/server -tls-cert certs/server.pem -tls-key certs/server-key.pem -tls-ca certs/ca.pem
+// go run ./grpc-twin/client -addr localhost:8080 -tls-ca certs/ca.pem -tls-cert certs/client.pem -tls-key certs/client-key.pem
+func main() {
+	dir := flag.String("dir", "certs", "output directory")
+	hosts := flag.String("hosts", "localhost,127.0.0.1,::1", "comma separated DNS names and IPs for the server certificate")
+	flag.Parse()
+
+	if err := os.MkdirAll(*dir, 0o755); err != nil {
+		panic(err)
+	}
+	ca, err := tlsutil.NewCA("playground-ca")
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, "ca.pem"), ca.CertPEM(), 0o644); err != nil {
+		panic(err)
+	}
+	if err := ca.IssueFiles(*dir, "server", "playground-server", strings.Split(*hosts, ",")...); err != nil {
+		panic(err)
+	}
+	if err := ca.IssueFiles(*dir, "client", "playground-client"); err != nil {
+		panic(err)
+	}
+	fmt.Printf("wrote ca.pem, server.pem, server-key.pem, client.pem, client-key.pem to %s\n", *dir)
+}
@@ -0,0 +1,217 @@
+package chaos
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+)
+
+const ScopeName = "github.com/dextercai/OpenTelemetry-Golang-Playground/chaos"
+
+// Any 未单独配置的路由/方法使用的规则
+const Any = "*"
+
+// Duration JSON里写成"150ms"这样的字符串
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
+
+// Latency 注入的延迟，Dist取值：
+//   - fixed：固定Mean
+//   - uniform：[Min, Max)均匀分布
+//   - normal：均值Mean、标准差Stddev的正态分布，小于0按0算
+//   - exponential：均值Mean的指数分布，长尾
+type Latency struct {
+	Rate   float64  `json:"rate"` // 命中概率，0按1算
+	Dist   string   `json:"dist"`
+	Mean   Duration `json:"mean,omitempty"`
+	Stddev Duration `json:"stddev,omitempty"`
+	Min    Duration `json:"min,omitempty"`
+	Max    Duration `json:"max,omitempty"`
+}
+
+// Fault 一个路由(HTTP)或方法(gRPC FullMethod)上的故障规则，各概率独立判定
+// 判定顺序：延迟 -> 断开连接 -> 返回错误 -> 不完整响应
+type Fault struct {
+	Latency *Latency `json:"latency,omitempty"`
+
+	ErrorRate  float64    `json:"errorRate,omitempty"`
+	HTTPStatus int        `json:"httpStatus,omitempty"` // 默认500
+	GRPCCode   codes.Code `json:"grpcCode,omitempty"`   // 默认Unavailable
+
+	DropRate    float64 `json:"dropRate,omitempty"`    // 不写任何响应直接断开连接
+	PartialRate float64 `json:"partialRate,omitempty"` // 只写出一半响应体后断开，仅HTTP
+}
+
+// Injector 按路由保存故障规则，规则可以通过ControlHandler在运行时修改
+type Injector struct {
+	tracer trace.Tracer
+
+	mu     sync.RWMutex
+	faults map[string]Fault
+	rng    *rand.Rand
+}
+
+func NewInjector() *Injector {
+	return &Injector{
+		tracer: otel.Tracer(ScopeName),
+		faults: make(map[string]Fault),
+		rng:    rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+func (i *Injector) Set(route string, f Fault) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	i.faults[route] = f
+}
+
+func (i *Injector) Delete(route string) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	delete(i.faults, route)
+}
+
+func (i *Injector) lookup(route string) (Fault, bool) {
+	i.mu.RLock()
+	defer i.mu.RUnlock()
+	if f, ok := i.faults[route]; ok {
+		return f, true
+	}
+	f, ok := i.faults[Any]
+	return f, ok
+}
+
+// hit 以概率p返回true
+func (i *Injector) hit(p float64) bool {
+	if p <= 0 {
+		return false
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	return i.rng.Float64() < p
+}
+
+func (i *Injector) delay(l *Latency) time.Duration {
+	if l == nil {
+		return 0
+	}
+	rate := l.Rate
+	if rate == 0 {
+		rate = 1
+	}
+	if !i.hit(rate) {
+		return 0
+	}
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	var d float64
+	switch l.Dist {
+	case "uniform":
+		d = float64(l.Min) + i.rng.Float64()*float64(l.Max-l.Min)
+	case "normal":
+		d = math.Max(0, float64(l.Mean)+i.rng.NormFloat64()*float64(l.Stddev))
+	case "exponential":
+		d = i.rng.ExpFloat64() * float64(l.Mean)
+	default:
+		d = float64(l.Mean)
+	}
+	return time.Duration(d)
+}
+
+// sleep 等待d，调用方取消时提前返回
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func hasSpan(ctx context.Context) bool {
+	return trace.SpanFromContext(ctx).IsRecording()
+}
+
+// event 故障记录为Span事件；上游没有Span时(手动埋点的Twin在handler里才创建Span)单独开一个chaos Span
+func (i *Injector) event(ctx context.Context, name string, attrs ...attribute.KeyValue) {
+	span := trace.SpanFromContext(ctx)
+	if !hasSpan(ctx) {
+		_, span = i.tracer.Start(ctx, "chaos", trace.WithAttributes(attrs...))
+		defer span.End()
+	}
+	span.AddEvent(name, trace.WithAttributes(attrs...))
+}
+
+// ControlHandler 运行时查看和修改规则
+//
+//	GET    /chaos                      列出所有规则
+//	PUT    /chaos?route=/api           设置规则，body为Fault的JSON
+//	DELETE /chaos?route=/api           删除规则
+//
+// route为"*"时作用于所有未单独配置的路由，gRPC的route是FullMethod，如/echo.TestService/SayHello
+func (i *Injector) ControlHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := r.URL.Query().Get("route")
+		switch r.Method {
+		case http.MethodGet:
+			i.mu.RLock()
+			b, err := json.MarshalIndent(i.faults, "", "  ")
+			i.mu.RUnlock()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write(b)
+		case http.MethodPut, http.MethodPost:
+			if route == "" {
+				http.Error(w, "missing route", http.StatusBadRequest)
+				return
+			}
+			var f Fault
+			if err := json.NewDecoder(r.Body).Decode(&f); err != nil {
+				http.Error(w, fmt.Sprintf("decoding fault: %s", err), http.StatusBadRequest)
+				return
+			}
+			i.Set(route, f)
+			w.WriteHeader(http.StatusNoContent)
+		case http.MethodDelete:
+			if route == "" {
+				http.Error(w, "missing route", http.StatusBadRequest)
+				return
+			}
+			i.Delete(route)
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
@@ -0,0 +1,90 @@
+package chaos
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor 按FullMethod注入故障，otelgrpc的StatsHandler先于拦截器创建Span，事件直接记在Server Span上
+// 拦截器里拿不到底层连接，断开连接用Unavailable模拟，不完整响应用DataLoss模拟
+func (i *Injector) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		partial, err := i.inject(ctx, info.FullMethod)
+		if err != nil {
+			return nil, err
+		}
+		if partial {
+			return nil, status.Error(codes.DataLoss, "chaos: partial response")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor 与UnaryServerInterceptor相同的规则，在handler开始前判定
+// 不完整响应为发出第一条消息后，后续SendMsg返回DataLoss
+func (i *Injector) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		partial, err := i.inject(ss.Context(), info.FullMethod)
+		if err != nil {
+			return err
+		}
+		if partial {
+			ss = &partialStream{ServerStream: ss}
+		}
+		return handler(srv, ss)
+	}
+}
+
+// inject 依次判定延迟、断开连接、返回错误，命中不完整响应时partial为true，由调用方决定怎么截断
+func (i *Injector) inject(ctx context.Context, method string) (partial bool, err error) {
+	f, ok := i.lookup(method)
+	if !ok {
+		return false, nil
+	}
+	routeAttr := attribute.String("chaos.route", method)
+
+	if d := i.delay(f.Latency); d > 0 {
+		i.event(ctx, "chaos.latency", routeAttr, attribute.Int64("chaos.delay_ms", d.Milliseconds()))
+		if err := sleep(ctx, d); err != nil {
+			return false, status.FromContextError(err).Err()
+		}
+	}
+
+	if i.hit(f.DropRate) {
+		i.event(ctx, "chaos.drop", routeAttr, semconv.RPCGRPCStatusCodeUnavailable)
+		return false, status.Error(codes.Unavailable, "chaos: connection dropped")
+	}
+
+	if i.hit(f.ErrorRate) {
+		code := f.GRPCCode
+		if code == codes.OK {
+			code = codes.Unavailable
+		}
+		i.event(ctx, "chaos.error", routeAttr, semconv.RPCGRPCStatusCodeKey.Int(int(code)))
+		return false, status.Error(code, "chaos: injected error")
+	}
+
+	if i.hit(f.PartialRate) {
+		i.event(ctx, "chaos.partial", routeAttr, semconv.RPCGRPCStatusCodeDataLoss)
+		return true, nil
+	}
+	return false, nil
+}
+
+type partialStream struct {
+	grpc.ServerStream
+	sent bool
+}
+
+func (s *partialStream) SendMsg(m any) error {
+	if s.sent {
+		return status.Error(codes.DataLoss, "chaos: partial response")
+	}
+	s.sent = true
+	return s.ServerStream.SendMsg(m)
+}
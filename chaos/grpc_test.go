@@ -0,0 +1,60 @@
+package chaos
+
+import (
+	"context"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type fakeStream struct {
+	grpc.ServerStream
+	sent int
+}
+
+func (s *fakeStream) Context() context.Context { return context.Background() }
+
+func (s *fakeStream) SendMsg(any) error {
+	s.sent++
+	return nil
+}
+
+func TestStreamServerInterceptor(t *testing.T) {
+	const method = "/echo.TestService/Watch"
+	info := &grpc.StreamServerInfo{FullMethod: method}
+	send3 := func(_ any, ss grpc.ServerStream) error {
+		for range 3 {
+			if err := ss.SendMsg(nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, tc := range []struct {
+		name string
+		f    Fault
+		code codes.Code
+		sent int
+	}{
+		{"none", Fault{}, codes.OK, 3},
+		{"error", Fault{ErrorRate: 1, GRPCCode: codes.ResourceExhausted}, codes.ResourceExhausted, 0},
+		{"drop", Fault{DropRate: 1}, codes.Unavailable, 0},
+		{"partial", Fault{PartialRate: 1}, codes.DataLoss, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			i := NewInjector()
+			i.Set(method, tc.f)
+			ss := &fakeStream{}
+			err := i.StreamServerInterceptor()(nil, ss, info, send3)
+			if got := status.Code(err); got != tc.code {
+				t.Errorf("code %v, want %v", got, tc.code)
+			}
+			if ss.sent != tc.sent {
+				t.Errorf("sent %d messages, want %d", ss.sent, tc.sent)
+			}
+		})
+	}
+}
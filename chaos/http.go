@@ -0,0 +1,94 @@
+package chaos
+
+import (
+	"bytes"
+	"net/http"
+	"strconv"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Handler 在next前注入故障，route与注册时的路由模式一致，也是ControlHandler里的route
+func (i *Injector) Handler(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, ok := i.lookup(route)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// otelhttp在外层时ctx里已有Server Span；手动埋点时只能拿到上游的trace上下文
+		ctx := r.Context()
+		if !hasSpan(ctx) {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+		}
+		routeAttr := attribute.String("chaos.route", route)
+
+		if d := i.delay(f.Latency); d > 0 {
+			i.event(ctx, "chaos.latency", routeAttr, attribute.Int64("chaos.delay_ms", d.Milliseconds()))
+			if err := sleep(ctx, d); err != nil {
+				return
+			}
+		}
+
+		if i.hit(f.DropRate) {
+			i.event(ctx, "chaos.drop", routeAttr)
+			drop(w)
+			return
+		}
+
+		if i.hit(f.ErrorRate) {
+			status := f.HTTPStatus
+			if status == 0 {
+				status = http.StatusInternalServerError
+			}
+			i.event(ctx, "chaos.error", routeAttr, semconv.HTTPResponseStatusCode(status))
+			http.Error(w, "chaos: injected error", status)
+			return
+		}
+
+		if i.hit(f.PartialRate) {
+			buf := &bufferedWriter{header: http.Header{}, status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+			body := buf.body.Bytes()
+			i.event(ctx, "chaos.partial", routeAttr,
+				attribute.Int("chaos.body.size", len(body)), attribute.Int("chaos.body.written", len(body)/2))
+			for k, v := range buf.header {
+				w.Header()[k] = v
+			}
+			// 声明完整长度但只写一半，客户端会读到unexpected EOF
+			w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+			w.WriteHeader(buf.status)
+			_, _ = w.Write(body[:len(body)/2])
+			_ = http.NewResponseController(w).Flush() // Hijack不会把已写的内容发出去
+			drop(w)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// drop 接管连接后直接关闭；不支持Hijack(如HTTP/2)时用ErrAbortHandler让net/http中断这个请求
+func drop(w http.ResponseWriter) {
+	conn, _, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		panic(http.ErrAbortHandler)
+	}
+	_ = conn.Close()
+}
+
+// bufferedWriter 先收下完整响应，再决定写出多少
+type bufferedWriter struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (b *bufferedWriter) Header() http.Header { return b.header }
+
+func (b *bufferedWriter) WriteHeader(code int) { b.status = code }
+
+func (b *bufferedWriter) Write(p []byte) (int, error) { return b.body.Write(p) }
@@ -0,0 +1,166 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+)
+
+// Header HTTP上传递剩余超时时间，值为Go的Duration格式(如"1.5s")
+// 传相对时间而不是绝对时间点，避免两端时钟不一致；gRPC自带grpc-timeout，不需要这个
+const Header = "X-Request-Timeout"
+
+// RemainingKey 发出/收到请求时剩余的时间
+const RemainingKey = attribute.Key("deadline.remaining_ms")
+
+// annotate 把ctx的剩余时间记到span上
+func annotate(ctx context.Context, span trace.Span) {
+	if d, ok := ctx.Deadline(); ok {
+		span.SetAttributes(RemainingKey.Int64(time.Until(d).Milliseconds()))
+	}
+}
+
+// skew 下游的deadline是上游的剩余时间加上收到请求的时刻，会比上游晚一点；
+// 上游先到期后断开连接或发RST_STREAM，下游的ctx是被取消而不是超时。离deadline不到skew的取消也按超时算
+const skew = 10 * time.Millisecond
+
+// expired ctx是否因为自己或上游的deadline到期而结束
+func expired(ctx context.Context) bool {
+	err := ctx.Err()
+	if err == nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	d, ok := ctx.Deadline()
+	return ok && time.Until(d) < skew
+}
+
+// RecordCancel ctx被取消或超时时记到span上，返回ctx.Err()
+// 超时和调用方主动取消分开记录，便于在Trace里区分是谁放弃了这次请求
+func RecordCancel(ctx context.Context, span trace.Span) error {
+	err := ctx.Err()
+	if err == nil {
+		return nil
+	}
+	if expired(ctx) {
+		span.AddEvent("deadline exceeded")
+		span.SetStatus(codes.Error, "deadline exceeded")
+	} else {
+		span.AddEvent("canceled", trace.WithAttributes(attribute.String("cancel.cause", context.Cause(ctx).Error())))
+		span.SetStatus(codes.Error, "canceled")
+	}
+	return err
+}
+
+// Transport 请求的ctx带有deadline时，把剩余时间写到Header里
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if d, ok := req.Context().Deadline(); ok {
+			req = req.Clone(req.Context())
+			req.Header.Set(Header, time.Until(d).Round(time.Millisecond).String())
+			annotate(req.Context(), trace.SpanFromContext(req.Context()))
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// MaxTimeout Handler接受的最长超时，Header里更长的值按MaxTimeout处理，避免客户端让请求无限占用服务端
+const MaxTimeout = 30 * time.Second
+
+// Handler 按Header给请求的ctx设置超时，下游(包括gRPC调用)会继承这个deadline
+// Header无法解析或不大于0时返回400；handler超时后还没有写响应时返回504
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		if v := r.Header.Get(Header); v != "" {
+			d, err := time.ParseDuration(v)
+			if err != nil || d <= 0 {
+				http.Error(w, "invalid "+Header+": "+v, http.StatusBadRequest)
+				return
+			}
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, min(d, MaxTimeout))
+			defer cancel()
+		}
+		// 手动埋点的Twin在handler里才创建Span，这时只能记到handler自己的Span上(见RecordCancel)
+		span := trace.SpanFromContext(ctx)
+		if !span.IsRecording() {
+			span = nil
+		} else {
+			annotate(ctx, span)
+		}
+
+		rw := &responseWriter{ResponseWriter: w}
+		next.ServeHTTP(rw, r.WithContext(ctx))
+
+		if ctx.Err() == nil {
+			return
+		}
+		if span != nil {
+			_ = RecordCancel(ctx, span)
+		}
+		if !rw.wroteHeader && expired(ctx) {
+			http.Error(rw, "deadline exceeded", http.StatusGatewayTimeout)
+		}
+	})
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Unwrap 供http.ResponseController找到底层的ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// UnaryClientInterceptor 调用方没有设置deadline时使用timeout，并把剩余时间记到当前Span上
+// gRPC自身会通过grpc-timeout把deadline传给服务端
+func UnaryClientInterceptor(timeout time.Duration) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		if _, ok := ctx.Deadline(); !ok && timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		annotate(ctx, trace.SpanFromContext(ctx))
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// UnaryServerInterceptor 记录收到请求时剩余的时间，handler返回时ctx已被取消则记到Server Span上
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		span := trace.SpanFromContext(ctx)
+		annotate(ctx, span)
+		resp, err := handler(ctx, req)
+		_ = RecordCancel(ctx, span)
+		return resp, err
+	}
+}
@@ -0,0 +1,186 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/chaos"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// TestThreeHops client -> httpServer -> grpcServer，gRPC这一跳注入的延迟远超client的deadline
+func TestThreeHops(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	defer tp.Shutdown(context.Background())
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+	}()
+
+	// grpcServer
+	injector := chaos.NewInjector()
+	injector.Set(chaos.Any, chaos.Fault{Latency: &chaos.Latency{Dist: "fixed", Mean: chaos.Duration(5 * time.Second)}})
+	grpcCtx := make(chan context.Context, 1)
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler()),
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(),
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				defer func() { grpcCtx <- ctx }()
+				return handler(ctx, req)
+			},
+			injector.UnaryServerInterceptor()),
+	)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	downstream := healthpb.NewHealthClient(conn)
+
+	// httpServer，记下deadline.Handler最终写出的状态码
+	status := make(chan int, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rw := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		otelhttp.NewHandler(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := downstream.Check(r.Context(), &healthpb.HealthCheckRequest{}); err != nil {
+				return
+			}
+			_, _ = w.Write([]byte("ok"))
+		})), "httpServer").ServeHTTP(rw, r)
+		status <- rw.status
+	}))
+	defer srv.Close()
+
+	// client
+	ctx, span := otel.Tracer("client").Start(context.Background(), "client")
+	ctx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	client := &http.Client{Transport: otelhttp.NewTransport(Transport(nil))}
+	if resp, err := client.Do(req); err == nil {
+		resp.Body.Close()
+		t.Errorf("got %s before the client's deadline", resp.Status)
+	}
+	if err := RecordCancel(ctx, span); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("client RecordCancel = %v", err)
+	}
+	span.End()
+
+	if got := <-status; got != http.StatusGatewayTimeout {
+		t.Errorf("httpServer status %d, want 504", got)
+	}
+	if err := (<-grpcCtx).Err(); err == nil {
+		t.Error("grpc handler's ctx is not cancelled")
+	}
+
+	hops := map[string]trace.SpanKind{"client": trace.SpanKindInternal, "httpServer": trace.SpanKindServer, "grpc.health.v1.Health/Check": trace.SpanKindServer}
+	for _, s := range rec.Ended() {
+		kind, ok := hops[s.Name()]
+		if !ok || s.SpanKind() != kind {
+			continue
+		}
+		delete(hops, s.Name())
+		if s.Status().Code != codes.Error {
+			t.Errorf("%s: status %v, want Error", s.Name(), s.Status().Code)
+		}
+		var found bool
+		for _, e := range s.Events() {
+			found = found || e.Name == "deadline exceeded"
+		}
+		if !found {
+			t.Errorf("%s: no deadline exceeded event in %v", s.Name(), s.Events())
+		}
+	}
+	if len(hops) != 0 {
+		t.Errorf("missing spans %v", hops)
+	}
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusRecorder) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func TestHandlerTimeoutHeader(t *testing.T) {
+	for _, tc := range []struct {
+		header string
+		status int
+		max    time.Duration // 0为不应有deadline
+	}{
+		{"", http.StatusOK, 0},
+		{"500ms", http.StatusOK, 500 * time.Millisecond},
+		{"1h", http.StatusOK, MaxTimeout}, // 超过上限的按上限处理
+		{"0s", http.StatusBadRequest, 0},
+		{"-1s", http.StatusBadRequest, 0},
+		{"soon", http.StatusBadRequest, 0},
+	} {
+		var (
+			called    bool
+			remaining time.Duration
+			hasDL     bool
+		)
+		h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			var d time.Time
+			d, hasDL = r.Context().Deadline()
+			remaining = time.Until(d)
+		}))
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if tc.header != "" {
+			req.Header.Set(Header, tc.header)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+
+		if w.Code != tc.status {
+			t.Errorf("%q: status %d, want %d", tc.header, w.Code, tc.status)
+		}
+		if tc.status != http.StatusOK {
+			if called {
+				t.Errorf("%q: handler called for a rejected header", tc.header)
+			}
+			continue
+		}
+		switch {
+		case tc.max == 0 && hasDL:
+			t.Errorf("%q: unexpected deadline", tc.header)
+		case tc.max > 0 && (!hasDL || remaining > tc.max || remaining < tc.max-time.Second):
+			t.Errorf("%q: remaining %s, want about %s", tc.header, remaining, tc.max)
+		}
+	}
+}
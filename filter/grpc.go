@@ -0,0 +1,93 @@
+package filter
+
+import (
+	"context"
+	"strings"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"go.opentelemetry.io/otel"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+)
+
+// ServerStatsHandler 包在otelgrpc.NewServerHandler()外面
+// 命中otlp.Excluded的调用不交给inner，不产生Span和指标，但仍从metadata取出上游的Trace上下文
+func ServerStatsHandler(inner stats.Handler) stats.Handler {
+	return &statsHandler{Handler: inner}
+}
+
+// ClientStatsHandler 包在otelgrpc.NewClientHandler()外面，命中时仍把当前的Trace上下文写进metadata
+func ClientStatsHandler(inner stats.Handler) stats.Handler {
+	return &statsHandler{Handler: inner, client: true}
+}
+
+type statsHandler struct {
+	stats.Handler
+	client bool
+}
+
+// excludedKey 按handler区分，服务端被排除的调用在handler里再发起的客户端调用不受影响
+type excludedKey struct{ h *statsHandler }
+
+func (h *statsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	if !otlp.Excluded(h.call(ctx, info)) {
+		return h.Handler.TagRPC(ctx, info)
+	}
+	ctx = context.WithValue(ctx, excludedKey{h}, true)
+	prop := otel.GetTextMapPropagator()
+	if !h.client {
+		ctx = context.WithValue(ctx, excludedRequestKey{}, true)
+		md, _ := metadata.FromIncomingContext(ctx)
+		return prop.Extract(ctx, metadataCarrier(md))
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	prop.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+func (h *statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if excluded, _ := ctx.Value(excludedKey{h}).(bool); excluded {
+		return
+	}
+	h.Handler.HandleRPC(ctx, s)
+}
+
+func (h *statsHandler) call(ctx context.Context, info *stats.RPCTagInfo) otlp.Call {
+	c := otlp.Call{Method: info.FullMethodName}
+	var md metadata.MD
+	if h.client {
+		md, _ = metadata.FromOutgoingContext(ctx)
+	} else {
+		md, _ = metadata.FromIncomingContext(ctx)
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			c.Peer = p.Addr.String()
+		}
+	}
+	c.Header = func(key string) []string { return md.Get(key) }
+	return c
+}
+
+// metadataCarrier 与otelgrpc内部的实现相同，metadata的key都是小写
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	values := metadata.MD(c).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, strings.ToLower(k))
+	}
+	return keys
+}
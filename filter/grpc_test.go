@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestStatsHandlers(t *testing.T) {
+	rec, tp := newRecorder(t)
+
+	var got context.Context
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.StatsHandler(ServerStatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(tp)))),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			got = ctx
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(ClientStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tp)))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// 调用方已经在一条Trace里
+	parent := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{1, 2, 3},
+		SpanID:     trace.SpanID{4, 5, 6},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), parent)
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if spans := rec.Ended(); len(spans) != 0 {
+		t.Errorf("health check produced %d spans", len(spans))
+	}
+	if !Excluded(got) {
+		t.Error("Excluded(ctx) = false in the server handler")
+	}
+	// 客户端跳过了otelgrpc仍注入traceparent，服务端跳过了otelgrpc仍取出来
+	if sc := trace.SpanContextFromContext(got); sc.TraceID() != parent.TraceID() || sc.SpanID() != parent.SpanID() {
+		t.Errorf("server got span context %v, want %v", sc, parent)
+	}
+}
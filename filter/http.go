@@ -0,0 +1,49 @@
+package filter
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Handler 命中otlp.Excluded的请求绕过traced(otelhttp.NewHandler或手写的中间件)直接交给plain，
+// 但仍从Header里取出上游的Trace上下文，对下游的调用还在同一条Trace里
+// plain自己创建的Span不受影响，需要的话用Excluded判断后跳过
+//
+//	index := http.HandlerFunc(indexHandler)
+//	http.Handle("/", filter.Handler(otelhttp.NewHandler(index, "index"), index))
+func Handler(traced, plain http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !otlp.Excluded(httpCall(r)) {
+			traced.ServeHTTP(w, r)
+			return
+		}
+		ctx := r.Context()
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header))
+		}
+		ctx = context.WithValue(ctx, excludedRequestKey{}, true)
+		plain.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+type excludedRequestKey struct{}
+
+// Excluded ctx所在的请求是否被Handler或ServerStatsHandler排除
+func Excluded(ctx context.Context) bool {
+	excluded, _ := ctx.Value(excludedRequestKey{}).(bool)
+	return excluded
+}
+
+func httpCall(r *http.Request) otlp.Call {
+	return otlp.Call{
+		Method: r.Method,
+		Route:  r.URL.Path,
+		Header: r.Header.Values,
+		Peer:   r.RemoteAddr,
+	}
+}
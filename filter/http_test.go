@@ -0,0 +1,67 @@
+package filter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const traceparent = "00-0102030405060708090a0b0c0d0e0f10-0102030405060708-01"
+
+// newRecorder filter用全局的Propagator，未调用InitOtlpProvider时otlp.Excluded使用DefaultFilters
+func newRecorder(t *testing.T) (*tracetest.SpanRecorder, trace.TracerProvider) {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTextMapPropagator()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTextMapPropagator(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+	return rec, tp
+}
+
+func TestHandler(t *testing.T) {
+	for _, tc := range []struct {
+		path     string
+		excluded bool
+	}{
+		{"/healthz", true},
+		{"/api/do/1", false},
+	} {
+		t.Run(tc.path, func(t *testing.T) {
+			rec, tp := newRecorder(t)
+			var got context.Context
+			plain := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { got = r.Context() })
+			h := Handler(otelhttp.NewHandler(plain, "traced", otelhttp.WithTracerProvider(tp)), plain)
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			req.Header.Set("traceparent", traceparent)
+			h.ServeHTTP(httptest.NewRecorder(), req)
+
+			if n := len(rec.Ended()); (n == 0) != tc.excluded {
+				t.Errorf("got %d spans, excluded=%v", n, tc.excluded)
+			}
+			if Excluded(got) != tc.excluded {
+				t.Errorf("Excluded(ctx) = %v", Excluded(got))
+			}
+			// 被排除时ctx里是上游的Span，否则是otelhttp的Server Span，TraceID都来自上游
+			sc := trace.SpanContextFromContext(got)
+			if sc.TraceID().String() != "0102030405060708090a0b0c0d0e0f10" {
+				t.Errorf("trace context not propagated: %v", sc.TraceID())
+			}
+			if tc.excluded && !sc.IsRemote() {
+				t.Error("excluded request should carry the remote span context")
+			}
+		})
+	}
+}
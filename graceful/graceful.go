@@ -0,0 +1,84 @@
+package graceful
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+const (
+	// DefaultDrainDelay readiness置为false之后继续正常服务的时间，留给负载均衡摘除本实例
+	DefaultDrainDelay = 2 * time.Second
+	// DefaultTimeout 从停止接收请求到Telemetry导出完毕的总时间
+	DefaultTimeout = 15 * time.Second
+)
+
+var draining atomic.Bool
+
+// Ready 开始关闭后返回false
+func Ready() bool {
+	return !draining.Load()
+}
+
+// NotifyContext 收到SIGINT或SIGTERM时取消
+func NotifyContext(parent context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(parent, os.Interrupt, syscall.SIGTERM)
+}
+
+// Shutdown 按顺序关闭：
+//  1. readiness置为false，等待drainDelay，期间仍正常处理请求
+//  2. 依次调用stops，通常先是服务(停止接收新请求，等待在途请求)，最后是otlp.Shutdown
+//
+// 超过timeout后剩余的stops拿到的是已取消的ctx，各自尽快结束
+func Shutdown(drainDelay, timeout time.Duration, stops ...func(context.Context) error) error {
+	draining.Store(true)
+	time.Sleep(drainDelay)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var errs []error
+	for _, stop := range stops {
+		if err := stop(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// HTTP 等待在途请求完成，ctx到期时强制关闭剩余连接
+func HTTP(srv *http.Server) func(context.Context) error {
+	return func(ctx context.Context) error {
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			_ = srv.Close()
+		}
+		return err
+	}
+}
+
+// GRPC 与HTTP相同，GracefulStop等不到在途RPC结束时改为Stop
+func GRPC(s *grpc.Server) func(context.Context) error {
+	return func(ctx context.Context) error {
+		done := make(chan struct{})
+		go func() {
+			s.GracefulStop()
+			close(done)
+		}()
+		select {
+		case <-done:
+			return nil
+		case <-ctx.Done():
+			s.Stop()
+			<-done
+			return ctx.Err()
+		}
+	}
+}
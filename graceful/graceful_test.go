@@ -0,0 +1,84 @@
+package graceful
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"go.opentelemetry.io/otel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// TestShutdownFlushesInFlightSpan 关闭时正在处理的请求，它的Span也要送到Collector
+func TestShutdownFlushesInFlightSpan(t *testing.T) {
+	ctx := context.Background()
+	col, err := otlp.NewCollector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer col.Shutdown(ctx)
+
+	res, err := otlp.NewResource(ctx, semconv.ServiceName("graceful-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(otlp.EndpointOverrideEnv, "")
+	otlp.InitOtlpProvider(ctx, res, otlp.WithEndpoint(col.Endpoint()))
+	t.Cleanup(func() { draining.Store(false) })
+
+	started := make(chan struct{})
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, span := otel.Tracer("graceful").Start(r.Context(), "slow")
+		defer span.End()
+		close(started)
+		time.Sleep(300 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	}))
+	srv.Start()
+	defer srv.Close()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(srv.URL)
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	if !Ready() {
+		t.Fatal("not ready before Shutdown")
+	}
+	done := make(chan error, 1)
+	go func() { done <- Shutdown(100*time.Millisecond, 5*time.Second, HTTP(srv.Config), otlp.Shutdown) }()
+	// drainDelay期间readiness已经是false，请求照常处理
+	for Ready() {
+		time.Sleep(time.Millisecond)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("Shutdown: %v", err)
+	}
+	if got := <-body; got != "done" {
+		t.Errorf("in-flight request got %q, want done", got)
+	}
+
+	var found bool
+	for _, rs := range col.Spans() {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				found = found || s.Name == "slow"
+			}
+		}
+	}
+	if !found {
+		t.Error("span of the in-flight request did not reach the collector")
+	}
+}
@@ -2,82 +2,91 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/deadline"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/filter"
 	"github.com/dextercai/OpenTelemetry-Golang-Playground/grpc-twin/opt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/requestid"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/retry"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/baggage"
 	"go.opentelemetry.io/otel/codes"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
-	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"strings"
 
 	"time"
 )
 
 func Init() {
-	// applicationRes 通常一个服务实例共享同一个applicationRes
-	// 用于记录服务名，服务节点名等信息
-	applicationRes := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName("grpcClient"),
-		semconv.K8SNodeName("single-node"),
-	)
-
 	ctx := context.Background()
 
-	otlpClient := otlptracehttp.NewClient(otlptracehttp.WithEndpoint("10.10.12.221:14318"), otlptracehttp.WithInsecure())
-
-	traceExporter, err := otlptrace.New(ctx, otlpClient)
-	if err != nil {
-		panic(fmt.Sprintf("creating OTLP trace exporter: %w", err))
-	}
-	otel.SetTracerProvider(sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter),
-		sdktrace.WithResource(applicationRes),
-	))
-
-	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpoint("10.10.12.221:14318"), otlpmetrichttp.WithInsecure())
+	// applicationRes 通常一个服务实例共享同一个applicationRes
+	// 用于记录服务名，服务节点名等信息
+	// 主机、进程、容器、K8s等信息自动探测
+	applicationRes, err := otlp.NewResource(ctx, semconv.ServiceName("grpcClient"))
 	if err != nil {
-		panic(fmt.Sprintf("creating OTLP metric exporter: %w", err))
+		panic(err)
 	}
-	otel.SetMeterProvider(sdkmetric.NewMeterProvider(
-		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter, sdkmetric.WithInterval(time.Microsecond))),
-		sdkmetric.WithResource(applicationRes),
-	))
 
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otlp.InitOtlpProvider(ctx, applicationRes,
+		otlp.WithEndpoint("10.10.12.221:14318"),
+		otlp.WithMetricInterval(time.Microsecond),
+		otlp.WithPrometheus(":9465"),
+		otlp.WithRuntimeMetrics(),
+	)
 }
 func main() {
-	Init()
+	addr := flag.String("addr", ":8080", "grpc-twin server address, use localhost:8080 with TLS")
+	tlsCA := flag.String("tls-ca", "", "CA (PEM) for verifying the server, enables TLS; see go run ./certgen")
+	tlsCert := flag.String("tls-cert", "", "client certificate (PEM) for mTLS")
+	tlsKey := flag.String("tls-key", "", "client private key (PEM)")
+	flag.Parse()
 
-	counter, err := otel.GetMeterProvider().Meter("dev_meter").Int64Counter("success_test_count")
+	Init()
 
-	if err != nil {
-		return
+	creds := insecure.NewCredentials()
+	if *tlsCA != "" {
+		tlsCfg, err := tlsutil.ClientConfig(*tlsCA, *tlsCert, *tlsKey)
+		if err != nil {
+			panic(err)
+		}
+		creds = credentials.NewTLS(tlsCfg)
 	}
-	for i := 0; i < 500; i++ {
-		counter.Add(context.Background(), 1)
-		time.Sleep(1 * time.Second)
+	// 设置了PLAYGROUND_AUTH_SECRET时带上Token，服务端把身份记到enduser.id
+	var token auth.Token
+	if signer := auth.FromEnv(); signer != nil {
+		t, err := signer.Sign("caiwenzhe", time.Hour)
+		if err != nil {
+			panic(err)
+		}
+		token = auth.Token(t)
 	}
-	return
+
+	// 压测用loadgen，这里只发一次请求
 	ctx := context.Background()
 	dialOptions := []grpc.DialOption{
-		grpc.WithStatsHandler(otelgrpc.NewClientHandler()),
-		grpc.WithInsecure(),
+		grpc.WithStatsHandler(filter.ClientStatsHandler(tlsutil.StatsHandler(retry.AnnotateStatsHandler(otelgrpc.NewClientHandler())))),
+		// deadline在外层，超时覆盖所有重试
+		// 请求ID在重试外层，每次尝试带相同的ID
+		grpc.WithChainUnaryInterceptor(deadline.UnaryClientInterceptor(3*time.Second), requestid.UnaryClientInterceptor(), retry.UnaryClientInterceptor(retry.DefaultPolicy())),
+		grpc.WithTransportCredentials(creds),
+		grpc.WithPerRPCCredentials(token),
 	}
 
 	func(ctx context.Context) {
 		ctx, span := otel.Tracer("grpcClientTracer").Start(ctx, "grpcSayHelloStart")
 		defer span.End()
 
-		conn, err := grpc.Dial(":8080", dialOptions...)
+		conn, err := grpc.Dial(*addr, dialOptions...)
 		if err != nil {
 			fmt.Printf("连接服务端失败: %s", err)
 			span.AddEvent("失败")
@@ -91,11 +100,18 @@ func main() {
 
 		span.AddEvent("Req SayHello")
 
-		r, err := c.SayHello(ctx, &opt.EchoRequest{Name: "Sato"})
+		// 客户端设置deadline，gRPC通过grpc-timeout传给服务端
+		// 模拟不认识W3C baggage的老调用方，服务端按映射表把x-tenant-id提升为Baggage
+		callCtx := metadata.AppendToOutgoingContext(ctx, "x-tenant-id", "playground")
+		callCtx, cancel := context.WithTimeout(callCtx, 3*time.Second)
+		defer cancel()
+		var header metadata.MD
+		r, err := c.SayHello(callCtx, &opt.EchoRequest{Name: "Sato"}, grpc.Header(&header))
 		if err != nil {
 			fmt.Printf("调用服务端代码失败: %s", err)
 			span.SetStatus(codes.Error, "连接服务端失败")
 			span.RecordError(err)
+			_ = deadline.RecordCancel(callCtx, span)
 
 			return
 		}
@@ -113,7 +129,7 @@ func main() {
 			1, 2, 3, 4, 5, 6, 7,
 		}})
 
-		fmt.Printf("调用成功: %s", r.Message)
+		fmt.Printf("调用成功: %s，请求ID: %s", r.Message, strings.Join(header.Get(requestid.MetadataKey), ","))
 
 		counter, err := otel.Meter("dev_meter").Int64Counter("success_test_count")
 		if err != nil {
@@ -2,24 +2,33 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/bridge"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/chaos"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/deadline"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/filter"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/graceful"
 	"github.com/dextercai/OpenTelemetry-Golang-Playground/grpc-twin/opt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/health"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/requestid"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
 	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
-	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 	"google.golang.org/grpc/reflection"
 	"net"
+	"net/http"
+	"time"
 )
 
 type serverImpl struct {
@@ -27,34 +36,21 @@ type serverImpl struct {
 }
 
 func Init() {
-	// applicationRes 通常一个服务实例共享同一个applicationRes
-	// 用于记录服务名，服务节点名等信息
-	applicationRes := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName("grpcServer"),
-		semconv.K8SNodeName("single-node"),
-	)
-
 	ctx := context.Background()
 
-	otlpClient := otlptracehttp.NewClient(otlptracehttp.WithEndpoint("10.10.12.221:14318"), otlptracehttp.WithInsecure())
-
-	traceExporter, err := otlptrace.New(ctx, otlpClient)
-	if err != nil {
-		panic(fmt.Sprintf("creating OTLP trace exporter: %w", err))
-	}
-	otel.SetTracerProvider(sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter),
-		sdktrace.WithResource(applicationRes),
-	))
-
-	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpoint("10.10.12.221:14318"), otlpmetrichttp.WithInsecure())
+	// applicationRes 通常一个服务实例共享同一个applicationRes
+	// 用于记录服务名，服务节点名等信息
+	// 主机、进程、容器、K8s等信息自动探测
+	applicationRes, err := otlp.NewResource(ctx, semconv.ServiceName("grpcServer"))
 	if err != nil {
-		panic(fmt.Sprintf("creating OTLP metric exporter: %w", err))
+		panic(err)
 	}
-	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter))))
 
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otlp.InitOtlpProvider(ctx, applicationRes,
+		otlp.WithEndpoint("10.10.12.221:14318"),
+		otlp.WithPrometheus(":9464"),
+		otlp.WithRuntimeMetrics(),
+	)
 }
 
 func (s serverImpl) SayHello(ctx context.Context, request *opt.EchoRequest) (*opt.EchoReply, error) {
@@ -62,25 +58,33 @@ func (s serverImpl) SayHello(ctx context.Context, request *opt.EchoRequest) (*op
 	defer span.End()
 	span.AddEvent("Reply", trace.WithAttributes(
 		attribute.String("username", "unknown")))
+	span.AddEvent("baggage got:" + baggage.FromContext(ctx).String())
 	rpy := &opt.EchoReply{Message: request.GetName()}
 	return rpy, nil
 }
 
 func (s serverImpl) Add(ctx context.Context, request *opt.AddRequest) (*opt.AddReply, error) {
 	ctx, span := otel.Tracer("grpcTracer").Start(ctx, "grpcAddServerStart")
-	bag := baggage.FromContext(ctx)
 	defer span.End()
 	rpy := &opt.AddReply{}
 	for i := range request.GetFoo() {
 		rpy.Result += int64(i)
 	}
 	span.AddEvent("Done")
-	span.AddEvent("user id from baggage:" + bag.Member("user-id").Value())
+	// 身份来自认证拦截器，不再从Baggage里取
+	if p, ok := auth.FromContext(ctx); ok {
+		span.AddEvent("user id:" + p.Subject)
+	}
 
 	return rpy, nil
 }
 
 func main() {
+	tlsCert := flag.String("tls-cert", "", "server certificate (PEM), plaintext when empty; see go run ./certgen")
+	tlsKey := flag.String("tls-key", "", "server private key (PEM)")
+	tlsCA := flag.String("tls-ca", "", "CA (PEM) for verifying client certificates, enables mTLS")
+	flag.Parse()
+
 	Init()
 	lis, err := net.Listen("tcp", ":8080")
 	if err != nil {
@@ -88,16 +92,57 @@ func main() {
 		return
 	}
 
-	s := grpc.NewServer(
-		grpc.StatsHandler(otelgrpc.NewServerHandler()),
-	)
+	// 故障注入，规则通过:8081/chaos在运行时开关
+	injector := chaos.NewInjector()
+	control := &http.Server{Addr: ":8081", Handler: injector.ControlHandler()}
+	go func() {
+		if err := control.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("开启故障注入控制端口失败: %s", err)
+		}
+	}()
+
+	sigCtx, stop := graceful.NotifyContext(context.Background())
+	defer stop()
+
+	// 设置了PLAYGROUND_AUTH_SECRET时要求Bearer Token，健康检查和反射除外
+	verifier := auth.FromEnv()
+	// 不认识W3C baggage的调用方通过metadata(如x-tenant-id)传递Baggage
+	baggageTable, err := bridge.FromEnv()
+	if err != nil {
+		panic(err)
+	}
+	serverOpts := []grpc.ServerOption{
+		// 健康检查、反射等otlp.Filters命中的调用不产生Span，TLS连接的版本、客户端证书记到Server Span上
+		grpc.StatsHandler(filter.ServerStatsHandler(tlsutil.StatsHandler(otelgrpc.NewServerHandler()))),
+		// deadline在chaos外层，注入的延迟超过客户端deadline时也能记到Span上
+		// 请求ID在认证之前，被拒绝的调用也能在响应里拿到X-Request-ID
+		grpc.ChainUnaryInterceptor(deadline.UnaryServerInterceptor(), requestid.UnaryServerInterceptor(), auth.UnaryServerInterceptor(verifier), baggageTable.UnaryServerInterceptor(), injector.UnaryServerInterceptor()),
+		grpc.ChainStreamInterceptor(requestid.StreamServerInterceptor(), auth.StreamServerInterceptor(verifier), baggageTable.StreamServerInterceptor(), injector.StreamServerInterceptor()),
+	}
+	if *tlsCert != "" {
+		tlsCfg, err := tlsutil.ServerConfig(*tlsCert, *tlsKey, *tlsCA)
+		if err != nil {
+			panic(err)
+		}
+		serverOpts = append(serverOpts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	s := grpc.NewServer(serverOpts...)
 	opt.RegisterTestServiceServer(s, &serverImpl{})
+	// 正在关闭或Trace导不出去时为NOT_SERVING
+	healthpb.RegisterHealthServer(s, health.NewGRPCServer(sigCtx, time.Second))
 
 	reflection.Register(s) // 按需
 
-	err = s.Serve(lis)
-	if err != nil {
-		fmt.Printf("开启服务失败: %s", err)
-		return
+	go func() {
+		if err := s.Serve(lis); err != nil {
+			fmt.Printf("开启服务失败: %s", err)
+		}
+	}()
+
+	// 收到SIGINT/SIGTERM后先摘流量，再等在途RPC结束，最后导出Telemetry
+	<-sigCtx.Done()
+	fmt.Println("正在关闭...")
+	if err := graceful.Shutdown(graceful.DefaultDrainDelay, graceful.DefaultTimeout, graceful.GRPC(s), graceful.HTTP(control), otlp.Shutdown); err != nil {
+		fmt.Printf("关闭失败: %s\n", err)
 	}
 }
@@ -0,0 +1,70 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/graceful"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	grpchealth "google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+const (
+	LivenessPath  = "/healthz"
+	ReadinessPath = "/readyz"
+)
+
+// Ready 正在关闭，或Trace导不出去(Collector连不上、队列快满)时返回错误
+// Playground里Trace就是服务的产出，导不出去时宁可让负载均衡把流量切走
+func Ready() error {
+	if !graceful.Ready() {
+		return errors.New("shutting down")
+	}
+	return otlp.Health()
+}
+
+// Register 在mux上注册/healthz和/readyz
+// 不经过otelhttp和手写的中间件，探针请求不产生Span和指标
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc(LivenessPath, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok\n"))
+	})
+	mux.HandleFunc(ReadinessPath, func(w http.ResponseWriter, r *http.Request) {
+		if err := Ready(); err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok\n"))
+	})
+}
+
+// NewGRPCServer 返回grpc_health_v1的实现，服务名""表示整个Server
+// 每interval按Ready更新一次状态，直到ctx结束
+func NewGRPCServer(ctx context.Context, interval time.Duration) *grpchealth.Server {
+	s := grpchealth.NewServer()
+	update := func() {
+		if Ready() == nil {
+			s.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+		} else {
+			s.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+		}
+	}
+	update()
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				s.Shutdown()
+				return
+			case <-t.C:
+				update()
+			}
+		}
+	}()
+	return s
+}
@@ -0,0 +1,243 @@
+package health
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/filter"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeCollector /v1/traces按mode返回：正常200，failing时503，blocking时挂起直到release
+type fakeCollector struct {
+	mode    atomic.Int32
+	mu      sync.Mutex
+	release chan struct{}
+}
+
+const (
+	collectorOK = iota
+	collectorFailing
+	collectorBlocking
+)
+
+func (c *fakeCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch c.mode.Load() {
+	case collectorFailing:
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		return
+	case collectorBlocking:
+		c.mu.Lock()
+		release := c.release
+		c.mu.Unlock()
+		select {
+		case <-release:
+		case <-r.Context().Done():
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (c *fakeCollector) block() {
+	c.mu.Lock()
+	c.release = make(chan struct{})
+	c.mu.Unlock()
+	c.mode.Store(collectorBlocking)
+}
+
+func (c *fakeCollector) unblock() {
+	c.mode.Store(collectorOK)
+	c.mu.Lock()
+	close(c.release)
+	c.mu.Unlock()
+}
+
+// TestReadiness Collector失败或队列快满时/readyz和grpc_health_v1都变成不可用，恢复后变回来
+func TestReadiness(t *testing.T) {
+	ctx := context.Background()
+	col := &fakeCollector{}
+	srv := httptest.NewServer(col)
+	defer srv.Close()
+
+	// 导出失败会交给ErrorHandler，测试里不打印
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(error) {}))
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { log.Print(err) }))
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	res, err := otlp.NewResource(ctx, semconv.ServiceName("health-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv(otlp.EndpointOverrideEnv, "")
+	otlp.InitOtlpProvider(ctx, res, otlp.WithEndpoint(srv.Listener.Addr().String()))
+	defer func() {
+		col.mode.Store(collectorOK)
+		shutdownCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+		defer cancel()
+		_ = otlp.Shutdown(shutdownCtx)
+	}()
+	tp := otel.GetTracerProvider().(*sdktrace.TracerProvider)
+
+	mux := http.NewServeMux()
+	Register(mux)
+	grpcCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	gs := NewGRPCServer(grpcCtx, 10*time.Millisecond)
+
+	// flush 每个Span单独一批交给MultiExporter
+	flush := func(n int) {
+		t.Helper()
+		for i := 0; i < n; i++ {
+			_, span := otel.Tracer("health-test").Start(ctx, "op")
+			span.End()
+			if err := tp.ForceFlush(ctx); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	waitReady := func(want bool) {
+		t.Helper()
+		wantCode, wantStatus := http.StatusOK, healthpb.HealthCheckResponse_SERVING
+		if !want {
+			wantCode, wantStatus = http.StatusServiceUnavailable, healthpb.HealthCheckResponse_NOT_SERVING
+		}
+		var (
+			code   int
+			status healthpb.HealthCheckResponse_ServingStatus
+		)
+		for deadline := time.Now().Add(10 * time.Second); time.Now().Before(deadline); time.Sleep(20 * time.Millisecond) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, ReadinessPath, nil))
+			code = w.Code
+			resp, err := gs.Check(ctx, &healthpb.HealthCheckRequest{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			status = resp.Status
+			if code == wantCode && status == wantStatus {
+				return
+			}
+		}
+		t.Fatalf("%s = %d, grpc health = %s; want %d, %s", ReadinessPath, code, status, wantCode, wantStatus)
+	}
+
+	waitReady(true)
+
+	// 重试3次(约3.5s)后仍失败才算不可用
+	col.mode.Store(collectorFailing)
+	flush(1)
+	waitReady(false)
+	col.mode.Store(collectorOK)
+	flush(1)
+	waitReady(true)
+
+	// 第一批卡在发送中，后面的排队，超过队列的80%(64*0.8)
+	col.block()
+	flush(60)
+	waitReady(false)
+	col.unblock()
+	waitReady(true)
+
+	// 存活探针不看导出状态
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, LivenessPath, nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("%s = %d", LivenessPath, w.Code)
+	}
+}
+
+// TestProbesNotTraced 即使整个mux/Server都套了otelhttp、otelgrpc，默认过滤规则下探针也不产生Span
+func TestProbesNotTraced(t *testing.T) {
+	ctx := context.Background()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	defer tp.Shutdown(ctx)
+
+	mux := http.NewServeMux()
+	Register(mux)
+	mux.HandleFunc("/work", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(filter.Handler(otelhttp.NewHandler(mux, "server", otelhttp.WithTracerProvider(tp)), mux))
+	defer srv.Close()
+
+	get := func(path string) {
+		t.Helper()
+		resp, err := http.Get(srv.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	get(LivenessPath)
+	get(ReadinessPath)
+	if n := len(rec.Ended()); n != 0 {
+		t.Errorf("HTTP probes produced %d spans", n)
+	}
+	get("/work")
+	if n := len(rec.Ended()); n != 1 {
+		t.Errorf("/work produced %d spans, want 1", n)
+	}
+
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.StatsHandler(filter.ServerStatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(tp)))),
+		// 没有注册的方法也走一遍StatsHandler，用来确认过滤之外的调用仍有Span
+		grpc.UnknownServiceHandler(func(any, grpc.ServerStream) error { return nil }),
+	)
+	grpcCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	healthpb.RegisterHealthServer(s, NewGRPCServer(grpcCtx, time.Second))
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(filter.ClientStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tp)))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	before := len(rec.Ended())
+	if _, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(rec.Ended()) - before; n != 0 {
+		t.Errorf("gRPC health check produced %d spans", n)
+	}
+	// 其它方法照常有客户端和服务端两个Span，服务端的Span可能在客户端返回之后才结束
+	_ = conn.Invoke(ctx, "/playground.Unknown/Call", &healthpb.HealthCheckRequest{}, &healthpb.HealthCheckResponse{})
+	n := 0
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if n = len(rec.Ended()) - before; n >= 2 {
+			break
+		}
+	}
+	if n != 2 {
+		t.Errorf("non-health call produced %d spans, want 2", n)
+	}
+}
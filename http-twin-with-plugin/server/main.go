@@ -2,60 +2,118 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/bridge"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/chaos"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/deadline"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/filter"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/graceful"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/grpc-twin/opt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/health"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/requestid"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
 	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
-	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
 	"net/http"
 
-	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"go.opentelemetry.io/otel/trace"
-	"time"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
+// downstream 为nil时不调用grpc-twin
+var downstream opt.TestServiceClient
+
+// baggageTable 不认识W3C baggage的调用方通过这些Header传递Baggage
+var baggageTable bridge.Table
+
 func main() {
-	// applicationRes 通常一个服务实例共享同一个applicationRes
-	// 用于记录服务名，服务节点名等信息
-	applicationRes := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName("httpServer-plugin"),
-		semconv.K8SNodeName("single-node"),
-	)
+	downstreamAddr := flag.String("downstream", "", "grpc-twin address; when set every request also calls SayHello (client -> httpServer -> grpcServer)")
+	tlsCert := flag.String("tls-cert", "", "server certificate (PEM), plain http when empty; see go run ./certgen")
+	tlsKey := flag.String("tls-key", "", "server private key (PEM)")
+	tlsCA := flag.String("tls-ca", "", "CA (PEM) for verifying client certificates, enables mTLS")
+	flag.Parse()
 
 	ctx := context.Background()
 
-	otlpClient := otlptracehttp.NewClient(otlptracehttp.WithEndpoint("127.0.0.1:4318"), otlptracehttp.WithInsecure())
-
-	traceExporter, err := otlptrace.New(ctx, otlpClient)
-	if err != nil {
-		panic(fmt.Sprintf("creating OTLP trace exporter: %w", err))
-	}
-	otel.SetTracerProvider(sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter),
-		sdktrace.WithResource(applicationRes),
-		//sdktrace.WithSampler(sdktrace.TraceIDRatioBased(0.5)),
-		//tracesdk.WithSampler(tracesdk.ParentBased(tracesdk.TraceIDRatioBased(0.5))),
-	))
-
-	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpoint("127.0.0.1:4318"), otlpmetrichttp.WithInsecure())
+	// applicationRes 通常一个服务实例共享同一个applicationRes
+	// 用于记录服务名，服务节点名等信息
+	// 主机、进程、容器、K8s等信息自动探测
+	applicationRes, err := otlp.NewResource(ctx, semconv.ServiceName("httpServer-plugin"))
 	if err != nil {
-		panic(fmt.Sprintf("creating OTLP metric exporter: %w", err))
+		panic(err)
 	}
-	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter))))
 
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otlp.InitOtlpProvider(ctx, applicationRes,
+		otlp.WithPrometheus(":9468"),
+		otlp.WithRuntimeMetrics(),
+	)
 
-	http.Handle("/", otelhttp.NewHandler(http.HandlerFunc(indexHandler), "indexHandler", otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents)))
-	http.ListenAndServe(":3000", nil)
+	baggageTable, err = bridge.FromEnv()
+	if err != nil {
+		panic(err)
+	}
+	if *downstreamAddr != "" {
+		conn, err := grpc.NewClient(*downstreamAddr,
+			grpc.WithStatsHandler(filter.ClientStatsHandler(otelgrpc.NewClientHandler())),
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			// 把调用方的Token和请求ID转发给grpc-twin，映射的Baggage同时写进metadata
+			grpc.WithChainUnaryInterceptor(auth.UnaryClientInterceptor(), baggageTable.UnaryClientInterceptor(), requestid.UnaryClientInterceptor()),
+		)
+		if err != nil {
+			panic(err)
+		}
+		downstream = opt.NewTestServiceClient(conn)
+	}
 
+	// 故障注入，规则通过:3001/chaos在运行时开关；控制端口不经过认证，不和业务端口放在一起
+	injector := chaos.NewInjector()
+	control := &http.Server{Addr: ":3001", Handler: injector.ControlHandler()}
+	go func() {
+		if err := control.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("开启故障注入控制端口失败: %s", err)
+		}
+	}()
+	health.Register(http.DefaultServeMux)
+	// 设置了PLAYGROUND_AUTH_SECRET时要求Bearer Token，身份记到otelhttp的Server Span上
+	// 映射的Header提升为Baggage，indexHandler直接从ctx里取；请求ID在最外层，生成的ID也能提升为Baggage
+	index := requestid.Handler(baggageTable.Handler(auth.Handler(auth.FromEnv(), deadline.Handler(injector.Handler("/", http.HandlerFunc(indexHandler))))))
+	// otlp.Filters命中的请求不经过otelhttp，tlsutil.Handler在otelhttp里面，TLS信息记到Server Span上
+	http.Handle("/", filter.Handler(otelhttp.NewHandler(tlsutil.Handler(index), "indexHandler", otelhttp.WithMessageEvents(otelhttp.ReadEvents, otelhttp.WriteEvents)), index))
+	srv := &http.Server{Addr: ":3000"}
+	go func() {
+		var err error
+		if *tlsCert != "" {
+			srv.TLSConfig, err = tlsutil.ServerConfig(*tlsCert, *tlsKey, *tlsCA)
+			if err != nil {
+				panic(err)
+			}
+			err = srv.ListenAndServeTLS("", "")
+		} else {
+			err = srv.ListenAndServe()
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			panic(err)
+		}
+	}()
+
+	// 收到SIGINT/SIGTERM后先摘流量，再等在途请求结束，最后导出Telemetry
+	sigCtx, stop := graceful.NotifyContext(ctx)
+	defer stop()
+	<-sigCtx.Done()
+	fmt.Println("正在关闭...")
+	if err := graceful.Shutdown(graceful.DefaultDrainDelay, graceful.DefaultTimeout, graceful.HTTP(srv), graceful.HTTP(control), otlp.Shutdown); err != nil {
+		fmt.Printf("关闭失败: %s\n", err)
+	}
 }
 
 func indexHandler(w http.ResponseWriter, r *http.Request) {
@@ -65,17 +123,34 @@ func indexHandler(w http.ResponseWriter, r *http.Request) {
 
 	// ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(r.Header)) //从Header中取出传播的信息
 
-	ctx, span = otel.Tracer("doHandleTracer").Start(ctx, "doHandle", trace.WithAttributes(attribute.String("url", r.URL.String())))
+	tracer := otel.Tracer("doHandleTracer")
+	if filter.Excluded(ctx) {
+		// otlp.Filters命中的请求(如健康检查)不创建Span，上游的Trace上下文照常传给下游
+		tracer = noop.NewTracerProvider().Tracer("")
+	}
+	ctx, span = tracer.Start(ctx, "doHandle", trace.WithAttributes(attribute.String("url", r.URL.String())))
 
 	//bag := baggage.FromContext(ctx)
 	defer span.End()
 	span.AddEvent("doHandle 处理开始", trace.WithStackTrace(true))
 	span.AddEvent("baggage got:"+bag.String(), trace.WithAttributes(attribute.String("user-id", bag.Member("user-id").String())))
-	t := time.Now()
-	span.SetAttributes(attribute.String("process.time", t.Sub(time.Now()).String()))
+	clock := otlp.GetClock() // 配置了FakeClock时，同一次运行得到相同的时间
+	t := clock.Now()
+	span.SetAttributes(attribute.String("process.time", t.Sub(clock.Now()).String()))
 
 	counter, _ := otel.GetMeterProvider().Meter("httpServer").Int64Counter("indexHandlerCounter")
 	counter.Add(ctx, 1)
 
-	w.Write([]byte(time.Now().String()))
+	// 第三跳，ctx带着上游传来的deadline，gRPC会继续传下去
+	if downstream != nil {
+		if _, err := downstream.SayHello(ctx, &opt.EchoRequest{Name: "httpServer"}); err != nil {
+			span.RecordError(err)
+		}
+	}
+	// 上游已经超时或断开，不再写响应
+	if err := deadline.RecordCancel(ctx, span); err != nil {
+		return
+	}
+
+	w.Write([]byte(clock.Now().String()))
 }
@@ -2,53 +2,43 @@ package main
 
 import (
 	"context"
+	"flag"
 	"fmt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/deadline"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/http-twin/middleware"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/requestid"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/retry"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/baggage"
-	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
-	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
-	"go.opentelemetry.io/otel/propagation"
-	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
-	"go.opentelemetry.io/otel/sdk/resource"
-	sdktrace "go.opentelemetry.io/otel/sdk/trace"
-	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
 	"io"
 	"net/http"
 	"time"
 )
 
 func main() {
-	// applicationRes 通常一个服务实例共享同一个applicationRes
-	// 用于记录服务名，服务节点名等信息
-	applicationRes := resource.NewWithAttributes(
-		semconv.SchemaURL,
-		semconv.ServiceName("httpClient"),
-		semconv.K8SNodeName("single-node"),
-	)
+	tlsCA := flag.String("tls-ca", "", "CA (PEM) for verifying the server, switches to https; see go run ./certgen")
+	tlsCert := flag.String("tls-cert", "", "client certificate (PEM) for mTLS")
+	tlsKey := flag.String("tls-key", "", "client private key (PEM)")
+	flag.Parse()
 
 	ctx := context.Background()
 
-	otlpClient := otlptracehttp.NewClient(otlptracehttp.WithEndpoint("127.0.0.1:4318"), otlptracehttp.WithInsecure())
-
-	traceExporter, err := otlptrace.New(ctx, otlpClient)
-	if err != nil {
-		panic(fmt.Sprintf("creating OTLP trace exporter: %w", err))
-	}
-	otel.SetTracerProvider(sdktrace.NewTracerProvider(
-		sdktrace.WithBatcher(traceExporter),
-		sdktrace.WithResource(applicationRes),
-		//sdktrace.WithSampler(sdktrace.TraceIDRatioBased(0.5)),
-		//tracesdk.WithSampler(tracesdk.ParentBased(tracesdk.TraceIDRatioBased(0.5))),
-	))
-
-	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpoint("127.0.0.1:4318"), otlpmetrichttp.WithInsecure())
+	// applicationRes 通常一个服务实例共享同一个applicationRes
+	// 用于记录服务名，服务节点名等信息
+	// 主机、进程、容器、K8s等信息自动探测
+	applicationRes, err := otlp.NewResource(ctx, semconv.ServiceName("httpClient"))
 	if err != nil {
-		panic(fmt.Sprintf("creating OTLP metric exporter: %w", err))
+		panic(err)
 	}
-	otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExporter))))
 
-	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	otlp.InitOtlpProvider(ctx, applicationRes,
+		otlp.WithPrometheus(":9467"),
+		otlp.WithRuntimeMetrics(),
+	)
 
 	// 初始化结束
 
@@ -65,23 +55,62 @@ func main() {
 
 	// 向Ctx中注入Baggage信息
 	newCtx = baggage.ContextWithBaggage(newCtx, setMember)
+
+	// 整个请求(包括重试)最多3秒，剩余时间通过Header传给服务端
+	newCtx, cancel := context.WithTimeout(newCtx, 3*time.Second)
+	defer cancel()
+	url := "http://localhost:3000/api/do/123"
+	var base http.RoundTripper = http.DefaultTransport
+	if *tlsCA != "" {
+		tlsCfg, err := tlsutil.ClientConfig(*tlsCA, *tlsCert, *tlsKey)
+		if err != nil {
+			panic(err)
+		}
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = tlsCfg
+		base = t
+		url = "https://localhost:3000/api/do/123"
+	}
+	// 设置了PLAYGROUND_AUTH_SECRET时带上Token，服务端把身份记到enduser.id
+	if signer := auth.FromEnv(); signer != nil {
+		token, err := signer.Sign("caiwenzhe", time.Hour)
+		if err != nil {
+			panic(err)
+		}
+		base = auth.Transport(base, token)
+	}
+
 	span.AddEvent("SendRequest")
-	req, err := http.NewRequestWithContext(newCtx, "GET", "http://localhost:3000/api/do/123", nil)
-	carrier := propagation.HeaderCarrier(req.Header)
-	otel.GetTextMapPropagator().Inject(newCtx, carrier) // 注入到HttpHeader中进行传递
+	req, err := http.NewRequestWithContext(newCtx, "GET", url, nil)
 	if err != nil {
 		span.RecordError(err)
 		return
 	}
+	// 模拟不认识W3C baggage的老调用方，服务端按映射表把它提升为Baggage里的tenant.id
+	req.Header.Set("X-Tenant-ID", "playground")
 
-	client := http.Client{}
-	resp, err := client.Do(req)
+	// 注入HttpHeader、Client Span、耗时指标都由手写的Transport完成，对照plugin版本的otelhttp.NewTransport
+	//carrier := propagation.HeaderCarrier(req.Header)
+	//otel.GetTextMapPropagator().Inject(newCtx, carrier) // 注入到HttpHeader中进行传递
+	// 每次尝试是一个Client Span，AnnotateTransport在其上记录第几次尝试，requestid.Transport记录请求ID(每次尝试相同)
+	transport, err := middleware.NewTransport(requestid.Transport(deadline.Transport(retry.AnnotateTransport(base))))
 	if err != nil {
 		panic(err)
 	}
-	body, err := io.ReadAll(resp.Body)
-	defer resp.Body.Close()
-	fmt.Printf("%s", body)
+
+	client := http.Client{Transport: retry.NewTransport(transport, retry.DefaultPolicy())}
+	resp, err := client.Do(req)
+	if err != nil {
+		span.RecordError(err)
+		_ = deadline.RecordCancel(newCtx, span)
+		fmt.Printf("请求失败: %s\n", err)
+	} else {
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		fmt.Printf("%s", body)
+		// 用户反馈问题时提供这个ID，它就是这次请求的TraceID
+		fmt.Printf("\n请求ID: %s\n", resp.Header.Get(requestid.Header))
+	}
 
 	span.End()
 
@@ -0,0 +1,178 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const ScopeName = "github.com/dextercai/OpenTelemetry-Golang-Playground/http-twin/middleware"
+
+// Metrics 手写的HTTP服务端RED指标，对照otelhttp.NewHandler
+// otelhttp(v0.57)的指标还是旧的http.server.duration(ms)等，OTEL_SEMCONV_STABILITY_OPT_IN=http/dup只影响Span属性，
+// 两边指标名、单位、属性的对应关系见TestCompareWithOtelHTTP
+// Instrument只在NewMetrics里创建一次，不要在每个请求里重复创建
+type Metrics struct {
+	duration     metric.Float64Histogram
+	active       metric.Int64UpDownCounter
+	requestSize  metric.Int64Histogram
+	responseSize metric.Int64Histogram
+}
+
+func NewMetrics(mp metric.MeterProvider) (*Metrics, error) {
+	meter := mp.Meter(ScopeName)
+	m := &Metrics{}
+	var err error
+	m.duration, err = meter.Float64Histogram(semconv.HTTPServerRequestDurationName,
+		metric.WithUnit(semconv.HTTPServerRequestDurationUnit),
+		metric.WithDescription(semconv.HTTPServerRequestDurationDescription),
+		// semconv建议的分桶
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.active, err = meter.Int64UpDownCounter(semconv.HTTPServerActiveRequestsName,
+		metric.WithUnit(semconv.HTTPServerActiveRequestsUnit),
+		metric.WithDescription(semconv.HTTPServerActiveRequestsDescription),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.requestSize, err = meter.Int64Histogram(semconv.HTTPServerRequestBodySizeName,
+		metric.WithUnit(semconv.HTTPServerRequestBodySizeUnit),
+		metric.WithDescription(semconv.HTTPServerRequestBodySizeDescription),
+	)
+	if err != nil {
+		return nil, err
+	}
+	m.responseSize, err = meter.Int64Histogram(semconv.HTTPServerResponseBodySizeName,
+		metric.WithUnit(semconv.HTTPServerResponseBodySizeUnit),
+		metric.WithDescription(semconv.HTTPServerResponseBodySizeDescription),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Handler route填注册时的路由模式(如"/api/do/{id}")，不要填实际URL，否则http.route会变成高基数属性
+func (m *Metrics) Handler(route string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		// 手动埋点的Span在handler里才创建，这里先取出上游的trace上下文，让exemplar至少能关联到调用方的Span
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+
+		// active_requests只带method和scheme，此时还不知道状态码
+		activeAttrs := metric.WithAttributes(method(r.Method), scheme(r))
+		m.active.Add(ctx, 1, activeAttrs)
+		defer m.active.Add(ctx, -1, activeAttrs)
+
+		body := &countingBody{ReadCloser: r.Body}
+		if r.Body != nil && r.Body != http.NoBody {
+			r.Body = body
+		}
+		rw := &responseWriter{ResponseWriter: w, status: http.StatusOK}
+
+		next.ServeHTTP(rw, r)
+
+		attrs := []attribute.KeyValue{
+			method(r.Method),
+			scheme(r),
+			semconv.HTTPRoute(route),
+			semconv.HTTPResponseStatusCode(rw.status),
+			semconv.NetworkProtocolVersion(protocolVersion(r)),
+		}
+		if rw.status >= 500 {
+			attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(rw.status)))
+		}
+		set := metric.WithAttributeSet(attribute.NewSet(attrs...))
+
+		m.duration.Record(ctx, time.Since(start).Seconds(), set)
+		// 有Content-Length以它为准，handler不一定会把body读完；chunked时只能按实际读到的算
+		requestSize := body.n.Load()
+		if r.ContentLength > 0 {
+			requestSize = r.ContentLength
+		}
+		m.requestSize.Record(ctx, requestSize, set)
+		m.responseSize.Record(ctx, rw.written, set)
+	})
+}
+
+func method(m string) attribute.KeyValue {
+	switch m {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut, http.MethodPatch,
+		http.MethodDelete, http.MethodConnect, http.MethodOptions, http.MethodTrace:
+		return semconv.HTTPRequestMethodKey.String(m)
+	}
+	return semconv.HTTPRequestMethodOther
+}
+
+func scheme(r *http.Request) attribute.KeyValue {
+	if r.TLS != nil {
+		return semconv.URLScheme("https")
+	}
+	return semconv.URLScheme("http")
+}
+
+func protocolVersion(r *http.Request) string {
+	if r.ProtoMinor == 0 {
+		return strconv.Itoa(r.ProtoMajor)
+	}
+	return strconv.Itoa(r.ProtoMajor) + "." + strconv.Itoa(r.ProtoMinor)
+}
+
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// responseWriter 记录状态码和写出的字节数
+// 只实现了Flush；Hijack等其他可选接口不直接实现，调用方(如chaos的drop)要通过http.ResponseController，
+// 它会沿Unwrap找到底层的ResponseWriter
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	written     int64
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if !w.wroteHeader {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	n, err := w.ResponseWriter.Write(b)
+	w.written += int64(n)
+	return n, err
+}
+
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap 供http.ResponseController找到底层的ResponseWriter
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
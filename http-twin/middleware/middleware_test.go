@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// instrument 采集到的一个指标的名字、单位和属性Key
+type instrument struct {
+	unit string
+	keys []string
+}
+
+// serve 用wrap包一层同样的handler，发一个POST请求，返回采集到的指标
+func serve(t *testing.T, wrap func(http.Handler, *sdkmetric.MeterProvider) http.Handler) map[string]instrument {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+	srv := httptest.NewServer(wrap(h, mp))
+	defer srv.Close()
+	resp, err := http.Post(srv.URL+"/api/do/1", "text/plain", strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	out := make(map[string]instrument)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			out[m.Name] = instrument{unit: m.Unit, keys: attrKeys(m.Data)}
+		}
+	}
+	return out
+}
+
+func attrKeys(data metricdata.Aggregation) []string {
+	var set attribute.Set
+	switch d := data.(type) {
+	case metricdata.Histogram[float64]:
+		set = d.DataPoints[0].Attributes
+	case metricdata.Histogram[int64]:
+		set = d.DataPoints[0].Attributes
+	case metricdata.Sum[int64]:
+		set = d.DataPoints[0].Attributes
+	}
+	var keys []string
+	for _, kv := range set.ToSlice() {
+		keys = append(keys, string(kv.Key))
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// TestCompareWithOtelHTTP 记录手写中间件与otelhttp.NewHandler的差异
+// otelhttp v0.57即使设置了http/dup，指标仍只有旧名字(http/dup只影响Span属性)，两边按下表对应
+func TestCompareWithOtelHTTP(t *testing.T) {
+	t.Setenv("OTEL_SEMCONV_STABILITY_OPT_IN", "http/dup")
+
+	ours := serve(t, func(h http.Handler, mp *sdkmetric.MeterProvider) http.Handler {
+		m, err := NewMetrics(mp)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m.Handler("/api/do/{id}", h)
+	})
+	theirs := serve(t, func(h http.Handler, mp *sdkmetric.MeterProvider) http.Handler {
+		return otelhttp.NewHandler(h, "server", otelhttp.WithMeterProvider(mp))
+	})
+
+	pairs := []struct {
+		ours, theirs         string
+		oursUnit, theirsUnit string
+	}{
+		{"http.server.request.duration", "http.server.duration", "s", "ms"},
+		{"http.server.request.body.size", "http.server.request.size", "By", "By"},
+		{"http.server.response.body.size", "http.server.response.size", "By", "By"},
+		// otelhttp没有活跃请求数
+		{"http.server.active_requests", "", "{request}", ""},
+	}
+	// 旧属性名 -> semconv v1.26的新名字
+	renamed := map[string]string{
+		"http.method":          "http.request.method",
+		"http.scheme":          "url.scheme",
+		"http.status_code":     "http.response.status_code",
+		"net.protocol.version": "network.protocol.version",
+	}
+	// otelhttp多出的属性(服务端地址按semconv v1.26不再是指标属性)，手写版多出的属性
+	theirsOnly := []string{"net.host.name", "net.host.port", "net.protocol.name"}
+	oursOnly := []string{"http.route"}
+
+	if len(ours) != len(pairs) {
+		t.Errorf("middleware instruments = %v, want %d", ours, len(pairs))
+	}
+	for _, p := range pairs {
+		o, ok := ours[p.ours]
+		if !ok {
+			t.Errorf("middleware: missing %s", p.ours)
+			continue
+		}
+		if o.unit != p.oursUnit {
+			t.Errorf("%s unit = %q, want %q", p.ours, o.unit, p.oursUnit)
+		}
+		if p.theirs == "" {
+			continue
+		}
+		th, ok := theirs[p.theirs]
+		if !ok {
+			t.Errorf("otelhttp: missing %s (got %v)", p.theirs, theirs)
+			continue
+		}
+		if th.unit != p.theirsUnit {
+			t.Errorf("%s unit = %q, want %q", p.theirs, th.unit, p.theirsUnit)
+		}
+
+		var want []string
+		for _, k := range th.keys {
+			if n, ok := renamed[k]; ok {
+				want = append(want, n)
+			} else if !slices.Contains(theirsOnly, k) {
+				t.Errorf("%s: undocumented otelhttp attribute %s", p.theirs, k)
+			}
+		}
+		want = append(want, oursOnly...)
+		sort.Strings(want)
+		if strings.Join(o.keys, ",") != strings.Join(want, ",") {
+			t.Errorf("%s attributes = %v, want %v (from %s %v)", p.ours, o.keys, want, p.theirs, th.keys)
+		}
+	}
+	if len(theirs) != len(pairs)-1 {
+		t.Errorf("otelhttp instruments = %v, want %d", theirs, len(pairs)-1)
+	}
+}
+
+// chaos的drop通过http.ResponseController接管连接，经过Metrics.Handler后仍然可用
+func TestHijackThroughResponseController(t *testing.T) {
+	m, err := NewMetrics(sdkmetric.NewMeterProvider())
+	if err != nil {
+		t.Fatal(err)
+	}
+	hijacked := make(chan error, 1)
+	srv := httptest.NewServer(m.Handler("/", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, _, err := http.NewResponseController(w).Hijack()
+		if err == nil {
+			conn.Close()
+		}
+		hijacked <- err
+	})))
+	defer srv.Close()
+
+	if _, err := http.Get(srv.URL); err == nil {
+		t.Error("want connection error after hijack")
+	}
+	if err := <-hijacked; err != nil {
+		t.Fatalf("Hijack: %v", err)
+	}
+}
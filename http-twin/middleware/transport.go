@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport 手写的客户端埋点，对照otelhttp.NewTransport
+// 每次RoundTrip(包括重定向的每一跳)都是调用方Span下的一个Client Span，重发的请求带http.request.resend_count
+//
+// 和otelhttp一样，4xx同样标记为Error(客户端视角)；Span在响应Body读完或Close时才结束，
+// 包含读取Body的时间，调用方必须Close Body，否则Span不会结束
+//
+// 与otelhttp的区别：
+//   - 不记录ReadEvents/WriteEvents消息事件
+//   - 属性使用semconv v1.26的新名字(http.request.method、url.full等)，otelhttp默认仍是旧名字
+type Transport struct {
+	base     http.RoundTripper
+	tracer   trace.Tracer
+	duration metric.Float64Histogram
+}
+
+var _ http.RoundTripper = (*Transport)(nil)
+
+// NewTransport base为nil时使用http.DefaultTransport
+func NewTransport(base http.RoundTripper) (*Transport, error) {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	duration, err := otel.GetMeterProvider().Meter(ScopeName).Float64Histogram(semconv.HTTPClientRequestDurationName,
+		metric.WithUnit(semconv.HTTPClientRequestDurationUnit),
+		metric.WithDescription(semconv.HTTPClientRequestDurationDescription),
+		metric.WithExplicitBucketBoundaries(0.005, 0.01, 0.025, 0.05, 0.075, 0.1, 0.25, 0.5, 0.75, 1, 2.5, 5, 7.5, 10),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &Transport{
+		base:     base,
+		tracer:   otel.Tracer(ScopeName),
+		duration: duration,
+	}, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	attrs := []attribute.KeyValue{method(req.Method)}
+	attrs = append(attrs, serverAttrs(req)...)
+
+	spanAttrs := append([]attribute.KeyValue{semconv.URLFull(redactedURL(req))}, attrs...)
+	// 重定向时http.Client会把上一跳的响应挂在req.Response上
+	if resends := resendCount(req); resends > 0 {
+		spanAttrs = append(spanAttrs, semconv.HTTPRequestResendCount(resends))
+	}
+	ctx, span := t.tracer.Start(req.Context(), req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(spanAttrs...),
+	)
+
+	// RoundTripper不能修改传入的req，注入Header前先复制一份
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		attrs = append(attrs, semconv.ErrorTypeOther)
+		t.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+		span.End()
+		return nil, err
+	}
+
+	attrs = append(attrs, semconv.HTTPResponseStatusCode(resp.StatusCode))
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	// https时记录TLS版本和服务端证书
+	span.SetAttributes(tlsutil.Attributes(resp.TLS, false)...)
+	if resp.StatusCode >= 400 {
+		span.SetStatus(codes.Error, resp.Status)
+		attrs = append(attrs, semconv.ErrorTypeKey.String(strconv.Itoa(resp.StatusCode)))
+	}
+	t.duration.Record(ctx, time.Since(start).Seconds(), metric.WithAttributes(attrs...))
+	endOnBodyClose(resp, span)
+	return resp, nil
+}
+
+// endOnBodyClose 协议升级(101)时Body是可写的连接，不包装，直接结束Span
+func endOnBodyClose(resp *http.Response, span trace.Span) {
+	if resp.Body == nil || resp.Body == http.NoBody {
+		span.End()
+		return
+	}
+	if _, ok := resp.Body.(io.ReadWriteCloser); ok {
+		span.End()
+		return
+	}
+	resp.Body = &spanBody{ReadCloser: resp.Body, span: span}
+}
+
+// spanBody 读到EOF、读取出错或Close时结束Span
+type spanBody struct {
+	io.ReadCloser
+	span trace.Span
+	once sync.Once
+}
+
+func (b *spanBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	switch {
+	case err == io.EOF:
+		b.end()
+	case err != nil:
+		b.span.RecordError(err)
+		b.span.SetStatus(codes.Error, err.Error())
+		b.end()
+	}
+	return n, err
+}
+
+func (b *spanBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.end()
+	return err
+}
+
+func (b *spanBody) end() {
+	b.once.Do(func() { b.span.End() })
+}
+
+func serverAttrs(req *http.Request) []attribute.KeyValue {
+	host, portStr, err := net.SplitHostPort(req.URL.Host)
+	if err != nil {
+		host = req.URL.Host
+		portStr = "80"
+		if req.URL.Scheme == "https" {
+			portStr = "443"
+		}
+	}
+	attrs := []attribute.KeyValue{semconv.ServerAddress(host)}
+	if port, err := strconv.Atoi(portStr); err == nil {
+		attrs = append(attrs, semconv.ServerPort(port))
+	}
+	return attrs
+}
+
+func redactedURL(req *http.Request) string {
+	u := *req.URL
+	if u.User != nil {
+		u.User = nil
+	}
+	return u.String()
+}
+
+func resendCount(req *http.Request) int {
+	n := 0
+	for r := req.Response; r != nil && r.Request != nil; r = r.Request.Response {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,234 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// newTestTransport Transport用的是全局Provider，测试期间换成内存里的SpanRecorder
+func newTestTransport(t *testing.T, base http.RoundTripper) (*Transport, *tracetest.SpanRecorder) {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prevTP, prevProp := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevTP)
+		otel.SetTextMapPropagator(prevProp)
+		_ = tp.Shutdown(context.Background())
+	})
+
+	tr, err := NewTransport(base)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tr, rec
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestTransportRedirectResendCount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/a", http.RedirectHandler("/b", http.StatusFound))
+	mux.Handle("/b", http.RedirectHandler("/c", http.StatusFound))
+	mux.HandleFunc("/c", func(w http.ResponseWriter, r *http.Request) {})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	tr, rec := newTestTransport(t, nil)
+	resp, err := (&http.Client{Transport: tr}).Get(srv.URL + "/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	spans := rec.Ended()
+	if len(spans) != 3 {
+		t.Fatalf("got %d spans, want one per hop (3)", len(spans))
+	}
+	for i, s := range spans {
+		v, ok := attr(s, semconv.HTTPRequestResendCountKey)
+		switch {
+		case i == 0 && ok:
+			t.Errorf("first hop has %s=%d", semconv.HTTPRequestResendCountKey, v.AsInt64())
+		case i > 0 && v.AsInt64() != int64(i):
+			t.Errorf("hop %d: %s=%d, want %d", i, semconv.HTTPRequestResendCountKey, v.AsInt64(), i)
+		}
+	}
+}
+
+func TestTransportStatus(t *testing.T) {
+	for _, tc := range []struct {
+		code int
+		want codes.Code
+	}{
+		{http.StatusOK, codes.Unset},
+		{http.StatusNotFound, codes.Error},
+		{http.StatusServiceUnavailable, codes.Error},
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tc.code)
+		}))
+		tr, rec := newTestTransport(t, nil)
+		resp, err := (&http.Client{Transport: tr}).Get(srv.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		srv.Close()
+
+		s := rec.Ended()[0]
+		if s.Status().Code != tc.want {
+			t.Errorf("%d: status %v, want %v", tc.code, s.Status().Code, tc.want)
+		}
+		if v, _ := attr(s, semconv.HTTPResponseStatusCodeKey); v.AsInt64() != int64(tc.code) {
+			t.Errorf("%d: %s=%d", tc.code, semconv.HTTPResponseStatusCodeKey, v.AsInt64())
+		}
+	}
+}
+
+type failingTransport struct{ err error }
+
+func (f failingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, f.err
+}
+
+func TestTransportError(t *testing.T) {
+	want := errors.New("connection refused")
+	tr, rec := newTestTransport(t, failingTransport{want})
+	req, _ := http.NewRequest(http.MethodGet, "http://127.0.0.1:1/", nil)
+	if _, err := tr.RoundTrip(req); !errors.Is(err, want) {
+		t.Fatalf("got %v, want %v", err, want)
+	}
+
+	s := rec.Ended()[0]
+	if s.Status().Code != codes.Error || s.Status().Description != want.Error() {
+		t.Errorf("status %v %q", s.Status().Code, s.Status().Description)
+	}
+	if len(s.Events()) != 1 || s.Events()[0].Name != semconv.ExceptionEventName {
+		t.Errorf("events %v, want one exception", s.Events())
+	}
+}
+
+func TestTransportInjectsHeader(t *testing.T) {
+	got := make(chan string, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got <- r.Header.Get("Traceparent")
+	}))
+	defer srv.Close()
+
+	tr, rec := newTestTransport(t, nil)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	sc := rec.Ended()[0].SpanContext()
+	want := "00-" + sc.TraceID().String() + "-" + sc.SpanID().String() + "-01"
+	if h := <-got; h != want {
+		t.Errorf("traceparent %q, want %q", h, want)
+	}
+	// 注入的是复制出来的请求，调用方的req不应被修改
+	if req.Header.Get("Traceparent") != "" {
+		t.Error("caller's request header was modified")
+	}
+}
+
+func TestTransportRedactsUserinfo(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tr, rec := newTestTransport(t, nil)
+	u := strings.Replace(srv.URL, "://", "://alice:secret@", 1) + "/path?q=1"
+	req, _ := http.NewRequest(http.MethodGet, u, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	v, _ := attr(rec.Ended()[0], semconv.URLFullKey)
+	if want := srv.URL + "/path?q=1"; v.AsString() != want {
+		t.Errorf("%s=%q, want %q", semconv.URLFullKey, v.AsString(), want)
+	}
+	if req.URL.User == nil {
+		t.Error("caller's URL was modified")
+	}
+}
+
+// Span包含读取Body的时间，读完或Close后才结束
+func TestTransportEndsSpanOnBodyClose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer srv.Close()
+
+	tr, rec := newTestTransport(t, nil)
+	for _, finish := range []func(*http.Response){
+		func(resp *http.Response) { _, _ = io.ReadAll(resp.Body) },
+		func(resp *http.Response) { resp.Body.Close() },
+	} {
+		ended := len(rec.Ended())
+		req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := len(rec.Ended()) - ended; n != 0 {
+			t.Fatalf("%d spans ended before the body was consumed", n)
+		}
+		finish(resp)
+		if n := len(rec.Ended()) - ended; n != 1 {
+			t.Fatalf("%d spans ended after the body was consumed, want 1", n)
+		}
+		resp.Body.Close()
+	}
+}
+
+// https时Client Span上有TLS版本和服务端证书
+func TestTransportTLS(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	tr, rec := newTestTransport(t, srv.Client().Transport)
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	span := rec.Ended()[0]
+	if v, _ := attr(span, semconv.TLSProtocolVersionKey); v.AsString() != "1.3" {
+		t.Errorf("%s=%q, want 1.3", semconv.TLSProtocolVersionKey, v.AsString())
+	}
+	if v, _ := attr(span, semconv.TLSServerSubjectKey); !strings.Contains(v.AsString(), "Acme Co") {
+		t.Errorf("%s=%q, want the httptest certificate", semconv.TLSServerSubjectKey, v.AsString())
+	}
+	if _, ok := attr(span, semconv.TLSClientSubjectKey); ok {
+		t.Errorf("%s set without a client certificate", semconv.TLSClientSubjectKey)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// 配置FakeClock后，indexHandler里每次取时间都前进1ms，时间戳和响应内容都是固定的
+func TestIndexHandlerFakeClock(t *testing.T) {
+	ctx := context.Background()
+	col, err := otlp.NewCollector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer col.Shutdown(ctx)
+
+	t.Setenv(otlp.EndpointOverrideEnv, "")
+	t.Setenv(otlp.FakeClockEnv, "")
+	start := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	res, err := otlp.NewResource(ctx, semconv.ServiceName("httpServer-test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	otlp.InitOtlpProvider(ctx, res, otlp.WithEndpoint(col.Endpoint()), otlp.WithClock(otlp.NewFakeClock(start, time.Millisecond)))
+
+	w := httptest.NewRecorder()
+	indexHandler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if err := otlp.Shutdown(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	ms := func(n int) time.Time { return start.Add(time.Duration(n) * time.Millisecond) }
+	// 开始0ms，两个事件1、2ms，process.time取3、4ms，响应5ms，结束6ms
+	if got, want := w.Body.String(), ms(5).String(); got != want {
+		t.Errorf("body %q, want %q", got, want)
+	}
+
+	var span *tracepb.Span
+	for _, rs := range col.Spans() {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				if s.Name == "doHandle" {
+					span = s
+				}
+			}
+		}
+	}
+	if span == nil {
+		t.Fatalf("doHandle span not exported: %v", col.Spans())
+	}
+	if got := time.Unix(0, int64(span.StartTimeUnixNano)); !got.Equal(ms(0)) {
+		t.Errorf("start %s, want %s", got, ms(0))
+	}
+	if len(span.Events) != 2 {
+		t.Fatalf("got %d events, want 2", len(span.Events))
+	}
+	for i, e := range span.Events {
+		if got := time.Unix(0, int64(e.TimeUnixNano)); !got.Equal(ms(i + 1)) {
+			t.Errorf("event %q at %s, want %s", e.Name, got, ms(i+1))
+		}
+	}
+	if got := time.Unix(0, int64(span.EndTimeUnixNano)); !got.Equal(ms(6)) {
+		t.Errorf("end %s, want %s", got, ms(6))
+	}
+	var processTime string
+	for _, kv := range span.Attributes {
+		if kv.Key == "process.time" {
+			processTime = kv.Value.GetStringValue()
+		}
+	}
+	if processTime != "-1ms" {
+		t.Errorf("process.time = %q, want -1ms", processTime)
+	}
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/filter"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/grpc-twin/opt"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/http-twin/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// caller 发送一次请求
+type caller func(ctx context.Context) error
+
+// token为空时不带Authorization
+func newHTTPCaller(url, token string) (caller, error) {
+	transport, err := middleware.NewTransport(auth.Transport(&http.Transport{
+		MaxIdleConnsPerHost: 256,
+	}, token))
+	if err != nil {
+		return nil, err
+	}
+	client := &http.Client{Transport: transport}
+	return func(ctx context.Context) error {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	}, nil
+}
+
+func newGRPCCaller(addr, token string) (caller, error) {
+	conn, err := grpc.NewClient(addr,
+		grpc.WithStatsHandler(filter.ClientStatsHandler(otelgrpc.NewClientHandler())),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(auth.Token(token)),
+	)
+	if err != nil {
+		return nil, err
+	}
+	c := opt.NewTestServiceClient(conn)
+	return func(ctx context.Context) error {
+		_, err := c.SayHello(ctx, &opt.EchoRequest{Name: "loadgen"})
+		return err
+	}, nil
+}
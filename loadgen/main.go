@@ -0,0 +1,193 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/baggage"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 按给定的RPS压测HTTP/gRPC Twin，结束后输出延迟分位数
+// 每个请求都有一个带loadgen.*属性的根Span，run id同时放进Baggage传给服务端，方便在后端按批次筛选
+// go run ./loadgen -target http -rps 200 -ramp 10s -duration 1m -concurrency 32
+// go run ./loadgen -target grpc -addr 127.0.0.1:8080 -rps 50
+func main() {
+	target := flag.String("target", "http", "http or grpc")
+	addr := flag.String("addr", "", "target address, default http://localhost:3000/api/do/123 or 127.0.0.1:8080")
+	endpoint := flag.String("endpoint", "127.0.0.1:4318", "OTLP/HTTP endpoint for loadgen's own telemetry")
+	rps := flag.Float64("rps", 10, "requests per second after ramp-up")
+	ramp := flag.Duration("ramp", 0, "linearly ramp from 1 rps to -rps over this duration")
+	duration := flag.Duration("duration", 30*time.Second, "total duration including ramp-up")
+	concurrency := flag.Int("concurrency", 8, "number of concurrent workers")
+	flag.Parse()
+
+	if err := validate(*rps, *ramp, *duration, *concurrency); err != nil {
+		fmt.Printf("参数错误: %s\n", err)
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := context.Background()
+
+	applicationRes, err := otlp.NewResource(ctx, semconv.ServiceName("loadgen"))
+	if err != nil {
+		panic(err)
+	}
+	otlp.InitOtlpProvider(ctx, applicationRes, otlp.WithEndpoint(*endpoint))
+
+	// 设置了PLAYGROUND_AUTH_SECRET时每个请求都带Token
+	var token string
+	if signer := auth.FromEnv(); signer != nil {
+		token, err = signer.Sign("loadgen", *duration+time.Minute)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	var do caller
+	switch *target {
+	case "http":
+		if *addr == "" {
+			*addr = "http://localhost:3000/api/do/123"
+		}
+		do, err = newHTTPCaller(*addr, token)
+	case "grpc":
+		if *addr == "" {
+			*addr = "127.0.0.1:8080"
+		}
+		do, err = newGRPCCaller(*addr, token)
+	default:
+		err = fmt.Errorf("unknown target %q", *target)
+	}
+	if err != nil {
+		fmt.Printf("初始化失败: %s\n", err)
+		os.Exit(2)
+	}
+
+	runID := strconv.FormatInt(time.Now().UnixNano(), 36)
+	member, _ := baggage.NewMember("loadgen.run_id", runID)
+	bag, _ := baggage.New(member)
+	ctx = baggage.ContextWithBaggage(ctx, bag)
+
+	fmt.Printf("run %s: %s %s, %.1f rps, ramp %s, duration %s, concurrency %d\n",
+		runID, *target, *addr, *rps, *ramp, *duration, *concurrency)
+
+	p := &profile{rps: *rps, ramp: *ramp}
+	res := run(ctx, do, p, *duration, *concurrency, []attribute.KeyValue{
+		attribute.String("loadgen.run_id", runID),
+		attribute.String("loadgen.target", *target),
+	})
+	res.print(os.Stdout)
+
+	// 等待Span发送完
+	if err := otlp.Shutdown(ctx); err != nil {
+		fmt.Printf("导出Telemetry失败: %s\n", err)
+	}
+}
+
+// validate rps为0或负数时发放间隔会变成+Inf或负数
+func validate(rps float64, ramp, duration time.Duration, concurrency int) error {
+	switch {
+	case !(rps > 0) || math.IsInf(rps, 1):
+		return fmt.Errorf("-rps must be a positive number, got %g", rps)
+	case ramp < 0:
+		return fmt.Errorf("-ramp must not be negative, got %s", ramp)
+	case duration <= 0:
+		return fmt.Errorf("-duration must be positive, got %s", duration)
+	case concurrency < 1:
+		return fmt.Errorf("-concurrency must be at least 1, got %d", concurrency)
+	}
+	return nil
+}
+
+// profile 前ramp时间内从1线性增加到rps，之后保持
+type profile struct {
+	rps  float64
+	ramp time.Duration
+}
+
+func (p *profile) at(elapsed time.Duration) float64 {
+	if p.ramp <= 0 || elapsed >= p.ramp {
+		return p.rps
+	}
+	return 1 + (p.rps-1)*float64(elapsed)/float64(p.ramp)
+}
+
+type job struct {
+	seq int64
+	rps float64
+}
+
+func run(ctx context.Context, do caller, p *profile, duration time.Duration, concurrency int, attrs []attribute.KeyValue) *result {
+	res := &result{}
+	tracer := otel.Tracer("github.com/dextercai/OpenTelemetry-Golang-Playground/loadgen")
+	jobs := make(chan job, concurrency)
+
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func(worker int) {
+			defer wg.Done()
+			for j := range jobs {
+				ctx, span := tracer.Start(ctx, "loadgen.request",
+					trace.WithAttributes(attrs...),
+					trace.WithAttributes(
+						attribute.Int("loadgen.worker", worker),
+						attribute.Int64("loadgen.seq", j.seq),
+						attribute.Float64("loadgen.rps", j.rps),
+					))
+				start := time.Now()
+				err := do(ctx)
+				elapsed := time.Since(start)
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, err.Error())
+				}
+				span.End()
+				res.record(elapsed, err)
+			}
+		}(w)
+	}
+
+	// 按当前速率发放请求，worker都忙时这次请求记为missed，而不是排队等待(否则延迟会被低估)
+	begin := time.Now()
+	next := begin
+	var seq int64
+	for {
+		now := time.Now()
+		elapsed := now.Sub(begin)
+		if elapsed >= duration {
+			break
+		}
+		rate := p.at(elapsed)
+		if now.Before(next) {
+			time.Sleep(next.Sub(now))
+			continue
+		}
+		next = next.Add(time.Duration(float64(time.Second) / rate))
+		seq++
+		select {
+		case jobs <- job{seq: seq, rps: rate}:
+		default:
+			atomic.AddInt64(&res.missed, 1)
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	res.wall = time.Since(begin)
+	return res
+}
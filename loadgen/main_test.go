@@ -0,0 +1,67 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValidate(t *testing.T) {
+	for _, tc := range []struct {
+		rps         float64
+		ramp        time.Duration
+		duration    time.Duration
+		concurrency int
+		ok          bool
+	}{
+		{10, 0, time.Second, 1, true},
+		{0.5, 10 * time.Second, time.Minute, 8, true},
+		{0, 0, time.Second, 1, false},
+		{-1, 0, time.Second, 1, false},
+		{math.NaN(), 0, time.Second, 1, false},
+		{math.Inf(1), 0, time.Second, 1, false},
+		{10, -time.Second, time.Second, 1, false},
+		{10, 0, 0, 1, false},
+		{10, 0, time.Second, 0, false},
+	} {
+		err := validate(tc.rps, tc.ramp, tc.duration, tc.concurrency)
+		if (err == nil) != tc.ok {
+			t.Errorf("validate(%g, %s, %s, %d) = %v", tc.rps, tc.ramp, tc.duration, tc.concurrency, err)
+		}
+	}
+}
+
+func TestProfileBelowOne(t *testing.T) {
+	// 向小于1的rps爬坡，速率始终为正
+	p := &profile{rps: 0.5, ramp: 10 * time.Second}
+	for _, elapsed := range []time.Duration{0, 5 * time.Second, 10 * time.Second, time.Minute} {
+		if r := p.at(elapsed); !(r > 0) {
+			t.Errorf("at(%s) = %g", elapsed, r)
+		}
+	}
+}
+
+func TestPercentile(t *testing.T) {
+	latencies := make([]time.Duration, 10)
+	for i := range latencies {
+		latencies[i] = time.Duration(i+1) * time.Millisecond
+	}
+	for _, tc := range []struct {
+		q    float64
+		want time.Duration
+	}{
+		{0.5, 5 * time.Millisecond},
+		{0.9, 9 * time.Millisecond},
+		{0.95, 10 * time.Millisecond},
+		{0.99, 10 * time.Millisecond},
+		{0.01, time.Millisecond},
+	} {
+		if got := percentile(latencies, tc.q); got != tc.want {
+			t.Errorf("p%g = %s, want %s", tc.q*100, got, tc.want)
+		}
+	}
+	// 只有一个样本时所有分位数都是它
+	if got := percentile(latencies[:1], 0.99); got != time.Millisecond {
+		t.Errorf("single sample p99 = %s", got)
+	}
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+type result struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int64
+	missed    int64 // worker都在忙而没有发出的请求
+	wall      time.Duration
+}
+
+func (r *result) record(d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.latencies = append(r.latencies, d)
+	if err != nil {
+		r.errors++
+	}
+}
+
+func (r *result) print(w io.Writer) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	n := len(r.latencies)
+	fmt.Fprintf(w, "requests %d, errors %d, missed %d, %.1f rps achieved\n",
+		n, r.errors, r.missed, float64(n)/r.wall.Seconds())
+	if n == 0 {
+		return
+	}
+	sort.Slice(r.latencies, func(i, j int) bool { return r.latencies[i] < r.latencies[j] })
+	for _, q := range []float64{0.5, 0.9, 0.95, 0.99} {
+		fmt.Fprintf(w, "p%-3g %s\n", q*100, percentile(r.latencies, q))
+	}
+	fmt.Fprintf(w, "max  %s\n", r.latencies[n-1])
+}
+
+// percentile latencies已排序，取最近秩ceil(q*n)
+func percentile(latencies []time.Duration, q float64) time.Duration {
+	i := int(math.Ceil(q*float64(len(latencies)))) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(latencies) {
+		i = len(latencies) - 1
+	}
+	return latencies[i]
+}
@@ -0,0 +1,243 @@
+package otlp
+
+import (
+	"context"
+	"log"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// overflowSet 超出上限的属性组合统一折叠到这一条序列上
+var overflowSet = attribute.NewSet(attribute.Bool("otel.metric.overflow", true))
+
+// cardinalityLimiter 限制每个Instrument的属性组合数量
+// 比如有人给indexHandlerCounter加了url属性，序列数会随url无限增长
+//
+// 计数的是视图去掉属性(ViewConfig.DropAttributes)之后的组合，和最终导出的序列一致；
+// 被视图去掉的高基数属性不会触发上限
+type cardinalityLimiter struct {
+	limit int
+	views []sdkmetric.View
+
+	mu     sync.Mutex
+	seen   map[string]map[attribute.Distinct]struct{}
+	warned map[string]bool
+
+	overflows metric.Int64Counter
+}
+
+// newLimitedMeterProvider 包装MeterProvider，只限制同步Instrument，异步Instrument的属性由回调自己控制
+// views与传给SDK的一致，用来得到每个Instrument在视图之后的属性
+func newLimitedMeterProvider(mp metric.MeterProvider, limit int, views []sdkmetric.View) metric.MeterProvider {
+	l := &cardinalityLimiter{
+		limit:  limit,
+		views:  views,
+		seen:   make(map[string]map[attribute.Distinct]struct{}),
+		warned: make(map[string]bool),
+	}
+	// 自身指标直接用被包装的Provider，不受限制
+	l.overflows, _ = mp.Meter("github.com/dextercai/OpenTelemetry-Golang-Playground/otlp").
+		Int64Counter("otlp.metric.cardinality.overflow", metric.WithUnit("{measurement}"))
+	return &limitedMeterProvider{MeterProvider: mp, limiter: l}
+}
+
+// instrumentLimit 一个同步Instrument的计数键与视图的属性过滤
+type instrumentLimit struct {
+	key     string
+	filter  attribute.Filter // 匹配的视图去掉了属性时非nil
+	limiter *cardinalityLimiter
+}
+
+func (i instrumentLimit) attributes(ctx context.Context, set attribute.Set) attribute.Set {
+	counted := set
+	if i.filter != nil {
+		counted, _ = set.Filter(i.filter)
+	}
+	return i.limiter.attributes(ctx, i.key, set, counted)
+}
+
+// attributes 按counted计数，未超限时原样返回set，交给SDK的视图去属性
+func (l *cardinalityLimiter) attributes(ctx context.Context, key string, set, counted attribute.Set) attribute.Set {
+	l.mu.Lock()
+	sets, ok := l.seen[key]
+	if !ok {
+		sets = make(map[attribute.Distinct]struct{})
+		l.seen[key] = sets
+	}
+	if _, ok := sets[counted.Equivalent()]; ok || len(sets) < l.limit {
+		sets[counted.Equivalent()] = struct{}{}
+		l.mu.Unlock()
+		return set
+	}
+	warn := !l.warned[key]
+	l.warned[key] = true
+	l.mu.Unlock()
+
+	if warn {
+		log.Printf("otlp: instrument %s exceeded cardinality limit %d, new attribute sets are folded into otel.metric.overflow=true", key, l.limit)
+	}
+	l.overflows.Add(ctx, 1, metric.WithAttributes(attribute.String("instrument", key)))
+	return overflowSet
+}
+
+type limitedMeterProvider struct {
+	metric.MeterProvider
+	limiter *cardinalityLimiter
+}
+
+func (p *limitedMeterProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	cfg := metric.NewMeterConfig(opts...)
+	scope := instrumentation.Scope{Name: name, Version: cfg.InstrumentationVersion(), SchemaURL: cfg.SchemaURL()}
+	return &limitedMeter{Meter: p.MeterProvider.Meter(name, opts...), scope: scope, limiter: p.limiter}
+}
+
+type limitedMeter struct {
+	metric.Meter
+	scope   instrumentation.Scope
+	limiter *cardinalityLimiter
+}
+
+// limit 按SDK的规则找到第一个匹配的视图，取它的属性过滤
+func (m *limitedMeter) limit(name string, kind sdkmetric.InstrumentKind, desc, unit string) instrumentLimit {
+	l := instrumentLimit{key: m.scope.Name + "/" + name, limiter: m.limiter}
+	inst := sdkmetric.Instrument{Name: name, Description: desc, Kind: kind, Unit: unit, Scope: m.scope}
+	for _, v := range m.limiter.views {
+		if s, ok := v(inst); ok {
+			l.filter = s.AttributeFilter
+			break
+		}
+	}
+	return l
+}
+
+func (m *limitedMeter) Int64Counter(name string, options ...metric.Int64CounterOption) (metric.Int64Counter, error) {
+	inst, err := m.Meter.Int64Counter(name, options...)
+	cfg := metric.NewInt64CounterConfig(options...)
+	return &limitedInt64Counter{Int64Counter: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindCounter, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Int64UpDownCounter(name string, options ...metric.Int64UpDownCounterOption) (metric.Int64UpDownCounter, error) {
+	inst, err := m.Meter.Int64UpDownCounter(name, options...)
+	cfg := metric.NewInt64UpDownCounterConfig(options...)
+	return &limitedInt64UpDownCounter{Int64UpDownCounter: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindUpDownCounter, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Int64Histogram(name string, options ...metric.Int64HistogramOption) (metric.Int64Histogram, error) {
+	inst, err := m.Meter.Int64Histogram(name, options...)
+	cfg := metric.NewInt64HistogramConfig(options...)
+	return &limitedInt64Histogram{Int64Histogram: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindHistogram, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Int64Gauge(name string, options ...metric.Int64GaugeOption) (metric.Int64Gauge, error) {
+	inst, err := m.Meter.Int64Gauge(name, options...)
+	cfg := metric.NewInt64GaugeConfig(options...)
+	return &limitedInt64Gauge{Int64Gauge: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindGauge, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Float64Counter(name string, options ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	inst, err := m.Meter.Float64Counter(name, options...)
+	cfg := metric.NewFloat64CounterConfig(options...)
+	return &limitedFloat64Counter{Float64Counter: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindCounter, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Float64UpDownCounter(name string, options ...metric.Float64UpDownCounterOption) (metric.Float64UpDownCounter, error) {
+	inst, err := m.Meter.Float64UpDownCounter(name, options...)
+	cfg := metric.NewFloat64UpDownCounterConfig(options...)
+	return &limitedFloat64UpDownCounter{Float64UpDownCounter: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindUpDownCounter, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Float64Histogram(name string, options ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	inst, err := m.Meter.Float64Histogram(name, options...)
+	cfg := metric.NewFloat64HistogramConfig(options...)
+	return &limitedFloat64Histogram{Float64Histogram: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindHistogram, cfg.Description(), cfg.Unit())}, err
+}
+
+func (m *limitedMeter) Float64Gauge(name string, options ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
+	inst, err := m.Meter.Float64Gauge(name, options...)
+	cfg := metric.NewFloat64GaugeConfig(options...)
+	return &limitedFloat64Gauge{Float64Gauge: inst, instrumentLimit: m.limit(name, sdkmetric.InstrumentKindGauge, cfg.Description(), cfg.Unit())}, err
+}
+
+type limitedInt64Counter struct {
+	metric.Int64Counter
+	instrumentLimit
+}
+
+func (c *limitedInt64Counter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	set := c.attributes(ctx, metric.NewAddConfig(options).Attributes())
+	c.Int64Counter.Add(ctx, incr, metric.WithAttributeSet(set))
+}
+
+type limitedInt64UpDownCounter struct {
+	metric.Int64UpDownCounter
+	instrumentLimit
+}
+
+func (c *limitedInt64UpDownCounter) Add(ctx context.Context, incr int64, options ...metric.AddOption) {
+	set := c.attributes(ctx, metric.NewAddConfig(options).Attributes())
+	c.Int64UpDownCounter.Add(ctx, incr, metric.WithAttributeSet(set))
+}
+
+type limitedInt64Histogram struct {
+	metric.Int64Histogram
+	instrumentLimit
+}
+
+func (h *limitedInt64Histogram) Record(ctx context.Context, value int64, options ...metric.RecordOption) {
+	set := h.attributes(ctx, metric.NewRecordConfig(options).Attributes())
+	h.Int64Histogram.Record(ctx, value, metric.WithAttributeSet(set))
+}
+
+type limitedInt64Gauge struct {
+	metric.Int64Gauge
+	instrumentLimit
+}
+
+func (g *limitedInt64Gauge) Record(ctx context.Context, value int64, options ...metric.RecordOption) {
+	set := g.attributes(ctx, metric.NewRecordConfig(options).Attributes())
+	g.Int64Gauge.Record(ctx, value, metric.WithAttributeSet(set))
+}
+
+type limitedFloat64Counter struct {
+	metric.Float64Counter
+	instrumentLimit
+}
+
+func (c *limitedFloat64Counter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	set := c.attributes(ctx, metric.NewAddConfig(options).Attributes())
+	c.Float64Counter.Add(ctx, incr, metric.WithAttributeSet(set))
+}
+
+type limitedFloat64UpDownCounter struct {
+	metric.Float64UpDownCounter
+	instrumentLimit
+}
+
+func (c *limitedFloat64UpDownCounter) Add(ctx context.Context, incr float64, options ...metric.AddOption) {
+	set := c.attributes(ctx, metric.NewAddConfig(options).Attributes())
+	c.Float64UpDownCounter.Add(ctx, incr, metric.WithAttributeSet(set))
+}
+
+type limitedFloat64Histogram struct {
+	metric.Float64Histogram
+	instrumentLimit
+}
+
+func (h *limitedFloat64Histogram) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	set := h.attributes(ctx, metric.NewRecordConfig(options).Attributes())
+	h.Float64Histogram.Record(ctx, value, metric.WithAttributeSet(set))
+}
+
+type limitedFloat64Gauge struct {
+	metric.Float64Gauge
+	instrumentLimit
+}
+
+func (g *limitedFloat64Gauge) Record(ctx context.Context, value float64, options ...metric.RecordOption) {
+	set := g.attributes(ctx, metric.NewRecordConfig(options).Attributes())
+	g.Float64Gauge.Record(ctx, value, metric.WithAttributeSet(set))
+}
@@ -0,0 +1,147 @@
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// limited 和InitOtlpProvider一样：SDK与限制器使用同一组视图
+func limited(t *testing.T, limit int, cfgs []ViewConfig) (metric.Meter, func() metricdata.ResourceMetrics) {
+	t.Helper()
+	reader := sdkmetric.NewManualReader()
+	vs := views(cfgs)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(vs...))
+	t.Cleanup(func() { _ = mp.Shutdown(context.Background()) })
+	meter := newLimitedMeterProvider(mp, limit, vs).Meter("limit-test")
+	return meter, func() metricdata.ResourceMetrics {
+		var rm metricdata.ResourceMetrics
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		return rm
+	}
+}
+
+// captureLog 测试期间把标准库log的输出收集起来
+func captureLog(t *testing.T) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	prev := log.Writer()
+	log.SetOutput(&buf)
+	t.Cleanup(func() { log.SetOutput(prev) })
+	return &buf
+}
+
+func find(rm metricdata.ResourceMetrics, name string) (metricdata.Metrics, bool) {
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m, true
+			}
+		}
+	}
+	return metricdata.Metrics{}, false
+}
+
+func overflowCount(t *testing.T, rm metricdata.ResourceMetrics, instrument string) int64 {
+	t.Helper()
+	m, ok := find(rm, "otlp.metric.cardinality.overflow")
+	if !ok {
+		return 0
+	}
+	var n int64
+	for _, dp := range m.Data.(metricdata.Sum[int64]).DataPoints {
+		if v, _ := dp.Attributes.Value("instrument"); v.AsString() == instrument {
+			n += dp.Value
+		}
+	}
+	return n
+}
+
+func TestCardinalityOverflow(t *testing.T) {
+	logs := captureLog(t)
+	meter, collect := limited(t, 3, nil)
+	c, _ := meter.Int64Counter("requests")
+	for i := 0; i < 10; i++ {
+		c.Add(context.Background(), 1, metric.WithAttributes(attribute.String("url", fmt.Sprintf("/%d", i))))
+	}
+	// 已记录过的组合不受上限影响
+	c.Add(context.Background(), 1, metric.WithAttributes(attribute.String("url", "/0")))
+
+	rm := collect()
+	m, _ := find(rm, "requests")
+	dps := m.Data.(metricdata.Sum[int64]).DataPoints
+	if len(dps) != 4 {
+		t.Fatalf("got %d series, want 3 + overflow", len(dps))
+	}
+	for _, dp := range dps {
+		if dp.Attributes.Equals(&overflowSet) {
+			if dp.Value != 7 {
+				t.Errorf("overflow series = %d, want 7", dp.Value)
+			}
+		} else if v, _ := dp.Attributes.Value("url"); v.AsString() == "/0" && dp.Value != 2 {
+			t.Errorf("/0 = %d, want 2", dp.Value)
+		}
+	}
+
+	if n := overflowCount(t, rm, "limit-test/requests"); n != 7 {
+		t.Errorf("self-metric = %d, want 7", n)
+	}
+	if n := strings.Count(logs.String(), "exceeded cardinality limit"); n != 1 {
+		t.Errorf("warning logged %d times, want once:\n%s", n, logs)
+	}
+}
+
+// 被视图去掉的属性不计入组合数
+func TestCardinalityAfterViews(t *testing.T) {
+	meter, collect := limited(t, 2, []ViewConfig{{Instrument: "latency", DropAttributes: []string{"user"}}})
+	h, _ := meter.Float64Histogram("latency")
+	for i := 0; i < 10; i++ {
+		h.Record(context.Background(), 1, metric.WithAttributes(
+			attribute.String("route", "/"), attribute.String("user", fmt.Sprintf("u%d", i))))
+	}
+
+	rm := collect()
+	m, _ := find(rm, "latency")
+	dps := m.Data.(metricdata.Histogram[float64]).DataPoints
+	if len(dps) != 1 || dps[0].Count != 10 {
+		t.Fatalf("got %d series, want one with all 10 measurements", len(dps))
+	}
+	if dps[0].Attributes.HasValue("otel.metric.overflow") {
+		t.Error("view-dropped attribute triggered the overflow series")
+	}
+	if n := overflowCount(t, rm, "limit-test/latency"); n != 0 {
+		t.Errorf("self-metric = %d, want 0", n)
+	}
+}
+
+func TestCardinalityGauge(t *testing.T) {
+	captureLog(t)
+	meter, collect := limited(t, 1, nil)
+	ig, _ := meter.Int64Gauge("queue")
+	fg, _ := meter.Float64Gauge("ratio")
+	for i := 0; i < 3; i++ {
+		ig.Record(context.Background(), int64(i), metric.WithAttributes(attribute.Int("n", i)))
+		fg.Record(context.Background(), float64(i), metric.WithAttributes(attribute.Int("n", i)))
+	}
+
+	rm := collect()
+	for _, name := range []string{"queue", "ratio"} {
+		if n := overflowCount(t, rm, "limit-test/"+name); n != 2 {
+			t.Errorf("%s: self-metric = %d, want 2", name, n)
+		}
+	}
+	m, _ := find(rm, "queue")
+	if dps := m.Data.(metricdata.Gauge[int64]).DataPoints; len(dps) != 2 {
+		t.Errorf("queue: got %d series, want 1 + overflow", len(dps))
+	}
+}
@@ -0,0 +1,101 @@
+package otlp
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Clock 业务代码与Span时间戳统一从这里取时间，测试时换成FakeClock即可得到固定的时间戳
+// Metric的时间戳仍由SDK取系统时间
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+// FakeClock 从start开始，每次Now()前进step
+type FakeClock struct {
+	mu   sync.Mutex
+	now  time.Time
+	step time.Duration
+}
+
+func NewFakeClock(start time.Time, step time.Duration) *FakeClock {
+	return &FakeClock{now: start, step: step}
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now
+	c.now = c.now.Add(c.step)
+	return now
+}
+
+// atomic.Value要求每次存入的具体类型相同，所以包一层
+type clockHolder struct{ Clock }
+
+var globalClock atomic.Value
+
+func init() {
+	globalClock.Store(clockHolder{systemClock{}})
+}
+
+// GetClock 返回InitOtlpProvider配置的时钟，未配置时为系统时钟
+func GetClock() Clock {
+	return globalClock.Load().(clockHolder).Clock
+}
+
+func setClock(c Clock) {
+	globalClock.Store(clockHolder{c})
+}
+
+// clockTracerProvider Span的开始、结束、事件时间改为从Clock获取
+// 调用方显式传了WithTimestamp时以调用方为准(后面的Option覆盖前面的)
+type clockTracerProvider struct {
+	trace.TracerProvider
+	clock Clock
+}
+
+func (p *clockTracerProvider) Tracer(name string, opts ...trace.TracerOption) trace.Tracer {
+	return &clockTracer{Tracer: p.TracerProvider.Tracer(name, opts...), clock: p.clock}
+}
+
+type clockTracer struct {
+	trace.Tracer
+	clock Clock
+}
+
+func (t *clockTracer) Start(ctx context.Context, name string, opts ...trace.SpanStartOption) (context.Context, trace.Span) {
+	opts = append([]trace.SpanStartOption{trace.WithTimestamp(t.clock.Now())}, opts...)
+	ctx, span := t.Tracer.Start(ctx, name, opts...)
+	s := &clockSpan{Span: span, clock: t.clock}
+	return trace.ContextWithSpan(ctx, s), s
+}
+
+type clockSpan struct {
+	trace.Span
+	clock Clock
+}
+
+func (s *clockSpan) End(opts ...trace.SpanEndOption) {
+	s.Span.End(append([]trace.SpanEndOption{trace.WithTimestamp(s.clock.Now())}, opts...)...)
+}
+
+func (s *clockSpan) AddEvent(name string, opts ...trace.EventOption) {
+	s.Span.AddEvent(name, append([]trace.EventOption{trace.WithTimestamp(s.clock.Now())}, opts...)...)
+}
+
+func (s *clockSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.Span.RecordError(err, append([]trace.EventOption{trace.WithTimestamp(s.clock.Now())}, opts...)...)
+}
+
+func (s *clockSpan) TracerProvider() trace.TracerProvider {
+	return &clockTracerProvider{TracerProvider: s.Span.TracerProvider(), clock: s.clock}
+}
@@ -0,0 +1,54 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var clockStart = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFakeClock(t *testing.T) {
+	c := NewFakeClock(clockStart, time.Millisecond)
+	for i := 0; i < 3; i++ {
+		if got, want := c.Now(), clockStart.Add(time.Duration(i)*time.Millisecond); !got.Equal(want) {
+			t.Fatalf("Now() #%d = %s, want %s", i, got, want)
+		}
+	}
+}
+
+// Span的开始、事件、结束依次从Clock取时间
+func TestClockTracerProvider(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	defer tp.Shutdown(context.Background())
+	ctp := &clockTracerProvider{TracerProvider: tp, clock: NewFakeClock(clockStart, time.Millisecond)}
+
+	ctx, span := ctp.Tracer("clock-test").Start(context.Background(), "op")
+	span.AddEvent("event")
+	span.RecordError(errors.New("boom"))
+	// 从ctx取出的Span同样走Clock
+	trace.SpanFromContext(ctx).AddEvent("from ctx")
+	// 调用方显式给的时间优先
+	explicit := clockStart.Add(time.Hour)
+	span.End(trace.WithTimestamp(explicit))
+
+	s := rec.Ended()[0]
+	ms := func(n int) time.Time { return clockStart.Add(time.Duration(n) * time.Millisecond) }
+	if !s.StartTime().Equal(ms(0)) {
+		t.Errorf("start %s, want %s", s.StartTime(), ms(0))
+	}
+	for i, e := range s.Events() {
+		if !e.Time.Equal(ms(i + 1)) {
+			t.Errorf("event %q at %s, want %s", e.Name, e.Time, ms(i+1))
+		}
+	}
+	if !s.EndTime().Equal(explicit) {
+		t.Errorf("end %s, want explicit %s", s.EndTime(), explicit)
+	}
+}
@@ -0,0 +1,178 @@
+package otlp
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Collector 内嵌的OTLP/HTTP接收端，收到的数据只保存在内存里，供twindiff等工具对比
+// 支持protobuf与JSON两种编码，以及gzip压缩
+type Collector struct {
+	srv *http.Server
+	ln  net.Listener
+
+	mu      sync.Mutex
+	spans   []*tracepb.ResourceSpans
+	metrics []*metricpb.ResourceMetrics
+	logs    []*logspb.ResourceLogs
+}
+
+// NewCollector 在addr上开始接收，addr为"127.0.0.1:0"时随机端口，实际地址见Endpoint
+func NewCollector(addr string) (*Collector, error) {
+	return NewTLSCollector(addr, nil)
+}
+
+// NewTLSCollector cfg非nil时以HTTPS接收，cfg.ClientAuth要求客户端证书时即mTLS
+func NewTLSCollector(addr string, cfg *tls.Config) (*Collector, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("otlp: collector listen on %s: %w", addr, err)
+	}
+	if cfg != nil {
+		ln = tls.NewListener(ln, cfg)
+	}
+	c := &Collector{ln: ln}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/traces", func(w http.ResponseWriter, r *http.Request) {
+		req := &coltracepb.ExportTraceServiceRequest{}
+		if c.decode(w, r, req) {
+			c.mu.Lock()
+			c.spans = append(c.spans, req.ResourceSpans...)
+			c.mu.Unlock()
+			c.reply(w, r, &coltracepb.ExportTraceServiceResponse{})
+		}
+	})
+	mux.HandleFunc("/v1/metrics", func(w http.ResponseWriter, r *http.Request) {
+		req := &colmetricpb.ExportMetricsServiceRequest{}
+		if c.decode(w, r, req) {
+			c.mu.Lock()
+			c.metrics = append(c.metrics, req.ResourceMetrics...)
+			c.mu.Unlock()
+			c.reply(w, r, &colmetricpb.ExportMetricsServiceResponse{})
+		}
+	})
+	mux.HandleFunc("/v1/logs", func(w http.ResponseWriter, r *http.Request) {
+		req := &collogspb.ExportLogsServiceRequest{}
+		if c.decode(w, r, req) {
+			c.mu.Lock()
+			c.logs = append(c.logs, req.ResourceLogs...)
+			c.mu.Unlock()
+			c.reply(w, r, &collogspb.ExportLogsServiceResponse{})
+		}
+	})
+
+	c.srv = &http.Server{Handler: mux}
+	go func() {
+		if err := c.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			fmt.Printf("otlp: collector on %s stopped: %s\n", ln.Addr(), err)
+		}
+	}()
+	return c, nil
+}
+
+// Endpoint 可直接传给WithEndpoint的host:port
+func (c *Collector) Endpoint() string {
+	return c.ln.Addr().String()
+}
+
+func (c *Collector) Spans() []*tracepb.ResourceSpans {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*tracepb.ResourceSpans(nil), c.spans...)
+}
+
+func (c *Collector) Metrics() []*metricpb.ResourceMetrics {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*metricpb.ResourceMetrics(nil), c.metrics...)
+}
+
+func (c *Collector) Logs() []*logspb.ResourceLogs {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*logspb.ResourceLogs(nil), c.logs...)
+}
+
+// Reset 清空已收到的数据，同一个Collector可以依次接收多个场景
+func (c *Collector) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.spans, c.metrics, c.logs = nil, nil, nil
+}
+
+func (c *Collector) Shutdown(ctx context.Context) error {
+	return c.srv.Shutdown(ctx)
+}
+
+func (c *Collector) decode(w http.ResponseWriter, r *http.Request, m proto.Message) bool {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return false
+		}
+		defer gz.Close()
+		body = gz
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	if isJSON(r) {
+		err = protojson.Unmarshal(b, m)
+	} else {
+		err = proto.Unmarshal(b, m)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func (c *Collector) reply(w http.ResponseWriter, r *http.Request, m proto.Message) {
+	var (
+		b   []byte
+		err error
+	)
+	if isJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		b, err = protojson.Marshal(m)
+	} else {
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		b, err = proto.Marshal(m)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_, _ = w.Write(b)
+}
+
+func isJSON(r *http.Request) bool {
+	return strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+}
@@ -0,0 +1,222 @@
+package otlp
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// Config InitOtlpProvider的配置，零值即默认行为
+type Config struct {
+	Endpoint   string // Collector的OTLP/HTTP地址
+	TraceFile  string // 非空时Trace额外以OTLP JSON写入该文件
+	MetricFile string // 非空时Metric额外以OTLP JSON写入该文件
+
+	TLS *tls.Config // 非nil时用HTTPS连接Collector，带客户端证书即mTLS
+
+	FileMaxBytes int64         // 文件超过该大小后切分，0为不限
+	FileMaxAge   time.Duration // 文件打开超过该时长后切分，0为不限
+
+	MetricInterval time.Duration // 推送模式Metric的导出间隔
+	PrometheusAddr string        // 非空时在该地址提供Prometheus的/metrics
+
+	Views []ViewConfig
+	// 推送模式(OTLP/文件)按Instrument类型选择Delta或累积，Prometheus固定为累积
+	Temporality map[sdkmetric.InstrumentKind]metricdata.Temporality
+	// 每个同步Instrument允许的属性组合数(按视图去掉属性之后计)，超出的折叠到otel.metric.overflow=true
+	// 默认2000，0为不限
+	CardinalityLimit int
+	// 直方图等聚合保留exemplar(trace_id/span_id)的条件，nil时沿用SDK默认
+	ExemplarFilter exemplar.Filter
+
+	RuntimeMetrics bool // 上报Go运行时与进程指标
+
+	IDSeed int64 // 非0时TraceID/SpanID由该种子确定，见NewIDGenerator
+	Clock  Clock // Span时间戳与GetClock()使用的时钟，nil为系统时钟
+
+	Filters []Filter // 不产生Span的请求，默认为DefaultFilters
+}
+
+type Option func(*Config)
+
+// 以下环境变量设置后覆盖对应的Option，供twindiff等工具在不改代码的情况下控制各个Twin
+const (
+	EndpointOverrideEnv = "PLAYGROUND_OTLP_ENDPOINT"   // Config.Endpoint
+	IDSeedEnv           = "PLAYGROUND_ID_SEED"         // Config.IDSeed
+	FakeClockEnv        = "PLAYGROUND_FAKE_CLOCK"      // RFC3339时间，Config.Clock换成从该时间开始、每次前进1ms的FakeClock
+	PrometheusAddrEnv   = "PLAYGROUND_PROMETHEUS_ADDR" // Config.PrometheusAddr，如":19464"；设为"off"关闭
+	// PEM文件路径，设置CA后Config.TLS换成用它校验Collector的配置，再设置CERT/KEY即mTLS
+	TLSCAEnv   = "PLAYGROUND_OTLP_CA"
+	TLSCertEnv = "PLAYGROUND_OTLP_CERT"
+	TLSKeyEnv  = "PLAYGROUND_OTLP_KEY"
+)
+
+func newConfig(opts []Option) *Config {
+	cfg := &Config{
+		Endpoint:         "127.0.0.1:4318",
+		MetricInterval:   time.Minute,
+		CardinalityLimit: 2000,
+		Filters:          slices.Clone(DefaultFilters),
+	}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+	if endpoint := os.Getenv(EndpointOverrideEnv); endpoint != "" {
+		cfg.Endpoint = endpoint
+	}
+	if addr := os.Getenv(PrometheusAddrEnv); addr == "off" {
+		cfg.PrometheusAddr = ""
+	} else if addr != "" {
+		cfg.PrometheusAddr = addr
+	}
+	if v := os.Getenv(IDSeedEnv); v != "" {
+		seed, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			panic(fmt.Sprintf("parsing %s: %v", IDSeedEnv, err))
+		}
+		cfg.IDSeed = seed
+	}
+	if v := os.Getenv(FakeClockEnv); v != "" {
+		start, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			panic(fmt.Sprintf("parsing %s: %v", FakeClockEnv, err))
+		}
+		cfg.Clock = NewFakeClock(start, time.Millisecond)
+	}
+	if ca := os.Getenv(TLSCAEnv); ca != "" {
+		tlsCfg, err := tlsutil.ClientConfig(ca, os.Getenv(TLSCertEnv), os.Getenv(TLSKeyEnv))
+		if err != nil {
+			panic(fmt.Sprintf("loading %s: %v", TLSCAEnv, err))
+		}
+		cfg.TLS = tlsCfg
+	}
+	return cfg
+}
+
+func WithEndpoint(endpoint string) Option {
+	return func(c *Config) {
+		c.Endpoint = endpoint
+	}
+}
+
+// WithTLS 证书可以用tlsutil.ClientConfig从文件加载
+func WithTLS(cfg *tls.Config) Option {
+	return func(c *Config) {
+		c.TLS = cfg
+	}
+}
+
+func WithTraceFile(path string) Option {
+	return func(c *Config) {
+		c.TraceFile = path
+	}
+}
+
+func WithMetricFile(path string) Option {
+	return func(c *Config) {
+		c.MetricFile = path
+	}
+}
+
+// WithFileRotation 文件导出按大小/时间切分，切分出的旧文件同样可以被replay读取
+func WithFileRotation(maxBytes int64, maxAge time.Duration) Option {
+	return func(c *Config) {
+		c.FileMaxBytes = maxBytes
+		c.FileMaxAge = maxAge
+	}
+}
+
+func WithMetricInterval(d time.Duration) Option {
+	return func(c *Config) {
+		c.MetricInterval = d
+	}
+}
+
+// WithPrometheus 额外开启Prometheus拉模式，addr形如":9464"，可以用PrometheusAddrEnv覆盖
+func WithPrometheus(addr string) Option {
+	return func(c *Config) {
+		c.PrometheusAddr = addr
+	}
+}
+
+func WithViews(views ...ViewConfig) Option {
+	return func(c *Config) {
+		c.Views = append(c.Views, views...)
+	}
+}
+
+func WithTemporality(kind sdkmetric.InstrumentKind, t metricdata.Temporality) Option {
+	return func(c *Config) {
+		if c.Temporality == nil {
+			c.Temporality = make(map[sdkmetric.InstrumentKind]metricdata.Temporality)
+		}
+		c.Temporality[kind] = t
+	}
+}
+
+// WithCardinalityLimit 默认已开启，上限2000；limit为0关闭
+func WithCardinalityLimit(limit int) Option {
+	return func(c *Config) {
+		c.CardinalityLimit = limit
+	}
+}
+
+// WithExemplarFilter mode取值与OTEL_METRICS_EXEMPLAR_FILTER一致：always_on、trace_based、always_off
+func WithExemplarFilter(mode string) Option {
+	return func(c *Config) {
+		switch mode {
+		case "always_on":
+			c.ExemplarFilter = exemplar.AlwaysOnFilter
+		case "trace_based":
+			c.ExemplarFilter = exemplar.TraceBasedFilter
+		case "always_off":
+			c.ExemplarFilter = exemplar.AlwaysOffFilter
+		default:
+			panic(fmt.Sprintf("unknown exemplar filter %q, want always_on, trace_based or always_off", mode))
+		}
+	}
+}
+
+// WithRuntimeMetrics 开启Go运行时(goroutine、内存、GC、调度延迟)与进程(CPU、RSS、FD)指标
+// 默认输出semconv命名的go.*指标，见DeprecatedRuntimeMetricsEnv
+func WithRuntimeMetrics() Option {
+	return func(c *Config) {
+		c.RuntimeMetrics = true
+	}
+}
+
+// WithIDSeed 生成可复现的TraceID/SpanID，仅用于测试和演示
+func WithIDSeed(seed int64) Option {
+	return func(c *Config) {
+		c.IDSeed = seed
+	}
+}
+
+// WithClock 比如NewFakeClock，让同一次运行得到相同的时间戳
+func WithClock(clock Clock) Option {
+	return func(c *Config) {
+		c.Clock = clock
+	}
+}
+
+// WithFilter 在DefaultFilters之外追加不追踪的请求
+func WithFilter(filters ...Filter) Option {
+	return func(c *Config) {
+		c.Filters = append(c.Filters, filters...)
+	}
+}
+
+// WithFilters 替换包括DefaultFilters在内的全部Filter，不带参数时所有请求都产生Span
+func WithFilters(filters ...Filter) Option {
+	return func(c *Config) {
+		c.Filters = append([]Filter(nil), filters...)
+	}
+}
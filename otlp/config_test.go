@@ -0,0 +1,49 @@
+package otlp
+
+import "testing"
+
+func TestWithExemplarFilter(t *testing.T) {
+	if cfg := newConfig([]Option{WithExemplarFilter("always_on")}); cfg.ExemplarFilter == nil {
+		t.Error("always_on: filter not set")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("typo in mode: want panic")
+		}
+	}()
+	newConfig([]Option{WithExemplarFilter("trace-based")})
+}
+
+func TestWithFilters(t *testing.T) {
+	extra := Filter{Routes: []string{"/metrics"}}
+	if cfg := newConfig([]Option{WithFilter(extra)}); len(cfg.Filters) != len(DefaultFilters)+1 {
+		t.Errorf("WithFilter: got %d filters, want defaults plus one", len(cfg.Filters))
+	}
+	if cfg := newConfig([]Option{WithFilters(extra)}); len(cfg.Filters) != 1 || cfg.Filters[0].Routes[0] != "/metrics" {
+		t.Errorf("WithFilters: got %v, want only %v", cfg.Filters, extra)
+	}
+	if cfg := newConfig([]Option{WithFilters()}); len(cfg.Filters) != 0 {
+		t.Errorf("WithFilters(): got %v, want none", cfg.Filters)
+	}
+	// 替换之后还可以再追加
+	if cfg := newConfig([]Option{WithFilters(), WithFilter(extra)}); len(cfg.Filters) != 1 {
+		t.Errorf("WithFilters then WithFilter: got %v", cfg.Filters)
+	}
+}
+
+// 生效的规则是DefaultFilters的副本，改动导出的变量不影响
+func TestDefaultFiltersCopied(t *testing.T) {
+	saved := DefaultFilters[0]
+	defer func() { DefaultFilters[0] = saved }()
+	cfg := newConfig(nil)
+	DefaultFilters[0] = Filter{Routes: []string{"/changed"}}
+
+	health := Call{Method: "/grpc.health.v1.Health/Check"}
+	if !Excluded(health) {
+		t.Error("Excluded: changing DefaultFilters affected the active filters")
+	}
+	if !cfg.Filters[0].Match(health) {
+		t.Error("newConfig: changing DefaultFilters affected Config.Filters")
+	}
+}
@@ -0,0 +1,217 @@
+package otlp
+
+import (
+	"bufio"
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// ResourceDetector 探测主机、进程、容器、K8s信息
+// 所有文件都相对Root读取，环境变量通过Getenv读取，测试时可以指向伪造的目录和环境
+type ResourceDetector struct {
+	Root   string              // 文件系统根目录，默认"/"
+	Getenv func(string) string // 默认os.Getenv
+
+	// K8s downward API挂载目录(相对Root)，默认/etc/podinfo，
+	// 约定其中有name、namespace、uid、nodename文件
+	PodInfoDir string
+}
+
+var _ resource.Detector = ResourceDetector{}
+
+// NewResource 自动探测运行环境，再合并OTEL_RESOURCE_ATTRIBUTES与调用方给出的属性(如service.name)，越靠后优先级越高
+func NewResource(ctx context.Context, attrs ...attribute.KeyValue) (*resource.Resource, error) {
+	return ResourceDetector{}.NewResource(ctx, attrs...)
+}
+
+// 各部分可能来自不同版本的semconv(比如SDK自带的telemetry.sdk.*)，统一交给MergeResources升级后再合并
+func (d ResourceDetector) NewResource(ctx context.Context, attrs ...attribute.KeyValue) (*resource.Resource, error) {
+	sdk, err := resource.New(ctx, resource.WithTelemetrySDK())
+	if err != nil {
+		return nil, err
+	}
+	detected, err := d.Detect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	env, err := resource.New(ctx, resource.WithFromEnv())
+	if err != nil {
+		return nil, err
+	}
+	return MergeResources(sdk, detected, env, resource.NewWithAttributes(semconv.SchemaURL, attrs...))
+}
+
+func (d ResourceDetector) Detect(context.Context) (*resource.Resource, error) {
+	var attrs []attribute.KeyValue
+	attrs = append(attrs, d.host()...)
+	attrs = append(attrs, d.process()...)
+	attrs = append(attrs, d.container()...)
+	attrs = append(attrs, d.k8s()...)
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+func (d ResourceDetector) path(p string) string {
+	root := d.Root
+	if root == "" {
+		root = "/"
+	}
+	return filepath.Join(root, p)
+}
+
+func (d ResourceDetector) getenv(key string) string {
+	if d.Getenv != nil {
+		return d.Getenv(key)
+	}
+	return os.Getenv(key)
+}
+
+func (d ResourceDetector) readFile(p string) string {
+	b, err := os.ReadFile(d.path(p))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func (d ResourceDetector) host() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.OSTypeKey.String(runtime.GOOS),
+	}
+	// 容器里os.Hostname()是Pod名，与/etc/hostname一致；伪造Root时以文件为准
+	name := d.readFile("etc/hostname")
+	if name == "" && d.Root == "" {
+		name, _ = os.Hostname()
+	}
+	if name != "" {
+		attrs = append(attrs, semconv.HostName(name))
+	}
+	if release := d.osRelease(); release["PRETTY_NAME"] != "" {
+		attrs = append(attrs, semconv.OSDescription(release["PRETTY_NAME"]))
+		if release["NAME"] != "" {
+			attrs = append(attrs, semconv.OSName(release["NAME"]))
+		}
+		if release["VERSION_ID"] != "" {
+			attrs = append(attrs, semconv.OSVersion(release["VERSION_ID"]))
+		}
+	}
+	return attrs
+}
+
+func (d ResourceDetector) osRelease() map[string]string {
+	out := make(map[string]string)
+	content := d.readFile("etc/os-release")
+	for _, line := range strings.Split(content, "\n") {
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		out[k] = strings.Trim(v, `"'`)
+	}
+	return out
+}
+
+func (d ResourceDetector) process() []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		semconv.ProcessPID(os.Getpid()),
+		semconv.ProcessRuntimeName("go"),
+		semconv.ProcessRuntimeVersion(runtime.Version()),
+		semconv.ProcessRuntimeDescription("go version " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH),
+	}
+	if exe, err := os.Executable(); err == nil {
+		attrs = append(attrs,
+			semconv.ProcessExecutableName(filepath.Base(exe)),
+			semconv.ProcessExecutablePath(exe),
+		)
+	}
+	return attrs
+}
+
+var containerIDRe = regexp.MustCompile(`[0-9a-f]{64}`)
+
+// containerMountPoints 容器运行时从宿主机的.../containers/<id>/下挂进来的文件
+// 宿主机上也有/var/lib/docker/containers/<id>/mounts/shm、overlay2/<64位hex>这类挂载，只认这几个挂载点才不会误判
+var containerMountPoints = map[string]bool{
+	"/etc/hostname":    true,
+	"/etc/hosts":       true,
+	"/etc/resolv.conf": true,
+}
+
+// container cgroup v1从/proc/self/cgroup取，cgroup v2下cgroup文件只有"0::/"，
+// 需要从mountinfo里挂载到/etc/hostname等文件的源路径(.../containers/<id>/hostname)取
+func (d ResourceDetector) container() []attribute.KeyValue {
+	for _, p := range []string{"proc/self/cgroup", "proc/self/mountinfo"} {
+		if id := d.scanContainerID(p); id != "" {
+			return []attribute.KeyValue{semconv.ContainerID(id)}
+		}
+	}
+	return nil
+}
+
+func (d ResourceDetector) scanContainerID(p string) string {
+	f, err := os.Open(d.path(p))
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasSuffix(p, "mountinfo") {
+			// 第4列是挂载源在其文件系统内的路径，第5列是挂载点
+			fields := strings.Fields(line)
+			if len(fields) < 5 || !containerMountPoints[fields[4]] {
+				continue
+			}
+			line = fields[3]
+		}
+		if id := containerIDRe.FindString(line); id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// k8s 优先读环境变量(Deployment里通过fieldRef注入)，其次读downward API卷
+func (d ResourceDetector) k8s() []attribute.KeyValue {
+	podInfo := d.PodInfoDir
+	if podInfo == "" {
+		podInfo = "etc/podinfo"
+	}
+	lookup := func(file string, envs ...string) string {
+		for _, e := range envs {
+			if v := d.getenv(e); v != "" {
+				return v
+			}
+		}
+		return d.readFile(filepath.Join(podInfo, file))
+	}
+
+	var attrs []attribute.KeyValue
+	if v := lookup("name", "K8S_POD_NAME", "POD_NAME"); v != "" {
+		attrs = append(attrs, semconv.K8SPodName(v))
+	}
+	if v := lookup("uid", "K8S_POD_UID", "POD_UID"); v != "" {
+		attrs = append(attrs, semconv.K8SPodUID(v))
+	}
+	namespace := lookup("namespace", "K8S_NAMESPACE_NAME", "POD_NAMESPACE")
+	if namespace == "" {
+		// ServiceAccount默认挂载的namespace文件
+		namespace = d.readFile("var/run/secrets/kubernetes.io/serviceaccount/namespace")
+	}
+	if namespace != "" {
+		attrs = append(attrs, semconv.K8SNamespaceName(namespace))
+	}
+	if v := lookup("nodename", "K8S_NODE_NAME", "NODE_NAME"); v != "" {
+		attrs = append(attrs, semconv.K8SNodeName(v))
+	}
+	return attrs
+}
@@ -0,0 +1,140 @@
+package otlp
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const (
+	testContainerID = "3f4b8c2d1e0a9f8e7d6c5b4a39281706f5e4d3c2b1a09f8e7d6c5b4a39281706"
+	otherHexID      = "aa11bb22cc33dd44ee55ff66aa11bb22cc33dd44ee55ff66aa11bb22cc33dd44"
+)
+
+// fakeRoot 按相对路径写入文件，返回可作为ResourceDetector.Root的目录
+func fakeRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for p, content := range files {
+		full := filepath.Join(root, p)
+		if err := os.MkdirAll(filepath.Dir(full), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(full, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func noEnv(string) string { return "" }
+
+func detect(t *testing.T, d ResourceDetector) attribute.Set {
+	t.Helper()
+	res, err := d.Detect(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *res.Set()
+}
+
+func TestDetectContainerID(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "cgroup v1",
+			files: map[string]string{
+				"proc/self/cgroup": "12:pids:/docker/" + testContainerID + "\n11:memory:/docker/" + testContainerID + "\n",
+			},
+			want: testContainerID,
+		},
+		{
+			name: "cgroup v2 mountinfo",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/\n",
+				"proc/self/mountinfo": strings.Join([]string{
+					"590 561 0:52 / / rw,relatime master:300 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/X,upperdir=/var/lib/docker/overlay2/" + otherHexID + "/diff",
+					"611 590 254:1 /var/lib/docker/containers/" + testContainerID + "/resolv.conf /etc/resolv.conf rw,relatime - ext4 /dev/vda1 rw",
+					"612 590 254:1 /var/lib/docker/containers/" + testContainerID + "/hostname /etc/hostname rw,relatime - ext4 /dev/vda1 rw",
+					"613 590 254:1 /var/lib/docker/containers/" + testContainerID + "/hosts /etc/hosts rw,relatime - ext4 /dev/vda1 rw",
+				}, "\n"),
+			},
+			want: testContainerID,
+		},
+		{
+			// 宿主机上能看到容器的shm和overlay2挂载，但挂载点都不是/etc下的文件
+			name: "docker host",
+			files: map[string]string{
+				"proc/self/cgroup": "0::/init.scope\n",
+				"proc/self/mountinfo": strings.Join([]string{
+					"29 1 254:1 / / rw,relatime shared:1 - ext4 /dev/vda1 rw",
+					"402 29 0:48 / /var/lib/docker/overlay2/" + otherHexID + "/merged rw,relatime shared:210 - overlay overlay rw",
+					"455 29 0:55 / /var/lib/docker/containers/" + testContainerID + "/mounts/shm rw,nosuid shared:233 - tmpfs shm rw,size=65536k",
+				}, "\n"),
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			attrs := detect(t, ResourceDetector{Root: fakeRoot(t, tc.files), Getenv: noEnv})
+			got, ok := attrs.Value(semconv.ContainerIDKey)
+			if tc.want == "" {
+				if ok {
+					t.Errorf("%s = %q, want none", semconv.ContainerIDKey, got.AsString())
+				}
+				return
+			}
+			if got.AsString() != tc.want {
+				t.Errorf("%s = %q, want %q", semconv.ContainerIDKey, got.AsString(), tc.want)
+			}
+		})
+	}
+}
+
+func podInfo() map[string]string {
+	return map[string]string{
+		"etc/podinfo/name":      "web-7d9f\n",
+		"etc/podinfo/namespace": "shop\n",
+		"etc/podinfo/uid":       "0c6a-11ef\n",
+		"etc/podinfo/nodename":  "node-1\n",
+	}
+}
+
+func TestDetectDownwardAPI(t *testing.T) {
+	attrs := detect(t, ResourceDetector{Root: fakeRoot(t, podInfo()), Getenv: noEnv})
+	for key, want := range map[attribute.Key]string{
+		semconv.K8SPodNameKey:       "web-7d9f",
+		semconv.K8SNamespaceNameKey: "shop",
+		semconv.K8SPodUIDKey:        "0c6a-11ef",
+		semconv.K8SNodeNameKey:      "node-1",
+	} {
+		if got, _ := attrs.Value(key); got.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+		}
+	}
+}
+
+// 环境变量优先于downward API文件，没设置的仍从文件读
+func TestDetectEnvOverFile(t *testing.T) {
+	env := map[string]string{"POD_NAME": "from-env", "K8S_NAMESPACE_NAME": "env-ns"}
+	attrs := detect(t, ResourceDetector{
+		Root:   fakeRoot(t, podInfo()),
+		Getenv: func(k string) string { return env[k] },
+	})
+	for key, want := range map[attribute.Key]string{
+		semconv.K8SPodNameKey:       "from-env",
+		semconv.K8SNamespaceNameKey: "env-ns",
+		semconv.K8SNodeNameKey:      "node-1",
+	} {
+		if got, _ := attrs.Value(key); got.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, got.AsString(), want)
+		}
+	}
+}
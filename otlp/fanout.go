@@ -0,0 +1,211 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Destination 描述扇出的一个发送目标
+// 每个目标都有独立的队列、超时与重试，互不阻塞
+type Destination struct {
+	Name     string
+	Exporter sdktrace.SpanExporter
+
+	QueueSize     int           // 队列可容纳的批次数，满了直接丢弃
+	Timeout       time.Duration // 单次发送超时
+	MaxRetries    int           // 失败后的最大重试次数
+	RetryInterval time.Duration // 首次重试间隔，之后指数递增
+}
+
+type destination struct {
+	Destination
+	queue chan []sdktrace.ReadOnlySpan
+	done  chan struct{}
+	attrs metric.MeasurementOption
+
+	lastErr atomic.Pointer[error] // 最近一批重试用完仍失败时的错误，成功后清空
+}
+
+// MultiExporter 把同一批Span发送到多个后端
+// 某个后端慢或者失败，只会导致它自己的队列积压/丢弃，不影响其他后端
+type MultiExporter struct {
+	dests []*destination
+
+	exported metric.Int64Counter
+	failed   metric.Int64Counter
+	dropped  metric.Int64Counter
+	retries  metric.Int64Counter
+
+	// mu 保护stopped与队列的关闭：ExportSpans持读锁检查并入队，Shutdown持写锁关闭，不会向已关闭的队列发送
+	mu       sync.RWMutex
+	stopOnce sync.Once
+	stopped  chan struct{}
+
+	// ctx Shutdown的ctx到期时取消，正在进行的发送和重试等待随之结束
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+var _ sdktrace.SpanExporter = (*MultiExporter)(nil)
+
+// SaturationRatio 队列占用达到这个比例时Health报告饱和，再多就要开始丢弃了
+const SaturationRatio = 0.8
+
+func NewMultiExporter(dests ...Destination) *MultiExporter {
+	m := &MultiExporter{stopped: make(chan struct{})}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+
+	// 自身指标走全局MeterProvider，初始化顺序无所谓，global包会做委托
+	meter := otel.Meter("github.com/dextercai/OpenTelemetry-Golang-Playground/otlp")
+	m.exported, _ = meter.Int64Counter("otlp.fanout.spans.exported", metric.WithUnit("{span}"))
+	m.failed, _ = meter.Int64Counter("otlp.fanout.spans.failed", metric.WithUnit("{span}"))
+	m.dropped, _ = meter.Int64Counter("otlp.fanout.spans.dropped", metric.WithUnit("{span}"))
+	m.retries, _ = meter.Int64Counter("otlp.fanout.retries", metric.WithUnit("{retry}"))
+	queueLen, _ := meter.Int64ObservableGauge("otlp.fanout.queue.length", metric.WithUnit("{batch}"))
+
+	for _, d := range dests {
+		if d.QueueSize <= 0 {
+			d.QueueSize = 64
+		}
+		if d.Timeout <= 0 {
+			d.Timeout = 10 * time.Second
+		}
+		if d.RetryInterval <= 0 {
+			d.RetryInterval = 500 * time.Millisecond
+		}
+		dest := &destination{
+			Destination: d,
+			queue:       make(chan []sdktrace.ReadOnlySpan, d.QueueSize),
+			done:        make(chan struct{}),
+			attrs:       metric.WithAttributes(attribute.String("destination", d.Name)),
+		}
+		m.dests = append(m.dests, dest)
+		go m.run(dest)
+	}
+
+	_, _ = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		for _, d := range m.dests {
+			o.ObserveInt64(queueLen, int64(len(d.queue)), d.attrs)
+		}
+		return nil
+	}, queueLen)
+
+	return m
+}
+
+// ExportSpans 只负责入队，真正的发送在每个目标自己的goroutine里
+func (m *MultiExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	select {
+	case <-m.stopped:
+		return errors.New("otlp: multi exporter is shut down")
+	default:
+	}
+	// BatchSpanProcessor在ExportSpans返回后会清空并复用这个切片，入队前要复制一份
+	spans = slices.Clone(spans)
+	for _, d := range m.dests {
+		select {
+		case d.queue <- spans:
+		default:
+			m.dropped.Add(ctx, int64(len(spans)), d.attrs)
+		}
+	}
+	return nil
+}
+
+// Shutdown 关闭所有队列，等待积压发送完毕（或ctx到期），再关闭各个Exporter
+func (m *MultiExporter) Shutdown(ctx context.Context) error {
+	m.stopOnce.Do(func() {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		close(m.stopped)
+		for _, d := range m.dests {
+			close(d.queue)
+		}
+	})
+	// 到期后放弃还在重试的批次，不再等待；正常结束时同样释放m.ctx
+	stop := context.AfterFunc(ctx, m.cancel)
+	defer stop()
+	defer m.cancel()
+
+	var errs []error
+	for _, d := range m.dests {
+		select {
+		case <-d.done:
+		case <-ctx.Done():
+			errs = append(errs, ctx.Err())
+		}
+		if err := d.Exporter.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Health 有目标最近一批发送失败，或队列已用掉SaturationRatio以上时返回错误
+// 还没有发送过时视为正常
+func (m *MultiExporter) Health() error {
+	var errs []error
+	for _, d := range m.dests {
+		if err := d.lastErr.Load(); err != nil {
+			errs = append(errs, fmt.Errorf("%s: last export failed: %w", d.Name, *err))
+		}
+		if n := len(d.queue); float64(n) >= SaturationRatio*float64(cap(d.queue)) {
+			errs = append(errs, fmt.Errorf("%s: queue saturated (%d/%d)", d.Name, n, cap(d.queue)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (m *MultiExporter) run(d *destination) {
+	defer close(d.done)
+	for spans := range d.queue {
+		m.send(d, spans)
+	}
+}
+
+func (m *MultiExporter) send(d *destination, spans []sdktrace.ReadOnlySpan) {
+	bg := m.ctx
+	interval := d.RetryInterval
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(bg, d.Timeout)
+		err := d.Exporter.ExportSpans(ctx, spans)
+		cancel()
+		if err == nil {
+			d.lastErr.Store(nil)
+			m.exported.Add(bg, int64(len(spans)), d.attrs)
+			return
+		}
+		if attempt >= d.MaxRetries || !wait(bg, interval) {
+			d.lastErr.Store(&err)
+			m.failed.Add(bg, int64(len(spans)), d.attrs)
+			otel.Handle(err)
+			return
+		}
+		m.retries.Add(bg, 1, d.attrs)
+		interval *= 2
+	}
+}
+
+// wait ctx被取消(Shutdown到期)时提前返回false
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
@@ -0,0 +1,190 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type countingExporter struct {
+	calls atomic.Int64
+	err   error
+}
+
+func (e *countingExporter) ExportSpans(ctx context.Context, spans []sdktrace.ReadOnlySpan) error {
+	e.calls.Add(1)
+	return e.err
+}
+
+func (e *countingExporter) Shutdown(context.Context) error { return nil }
+
+func spans(n int) []sdktrace.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, n)
+	return stubs.Snapshots()
+}
+
+// Shutdown与ExportSpans并发时不能向已关闭的队列发送
+func TestMultiExporterConcurrentShutdown(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		m := NewMultiExporter(Destination{Name: "a", Exporter: &countingExporter{}, QueueSize: 1})
+		var wg sync.WaitGroup
+		for j := 0; j < 8; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for k := 0; k < 100; k++ {
+					_ = m.ExportSpans(context.Background(), spans(1))
+				}
+			}()
+		}
+		if err := m.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		wg.Wait()
+		if err := m.ExportSpans(context.Background(), spans(1)); err == nil {
+			t.Fatal("ExportSpans after Shutdown: want error")
+		}
+	}
+}
+
+// Shutdown的ctx到期后不再等待重试间隔
+func TestMultiExporterShutdownAbortsRetry(t *testing.T) {
+	exp := &countingExporter{err: errors.New("unavailable")}
+	m := NewMultiExporter(Destination{Name: "a", Exporter: exp, MaxRetries: 5, RetryInterval: time.Hour})
+	if err := m.ExportSpans(context.Background(), spans(1)); err != nil {
+		t.Fatal(err)
+	}
+	for exp.calls.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if err := m.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Shutdown = %v, want deadline exceeded", err)
+	}
+	if d := time.Since(start); d > time.Second {
+		t.Fatalf("Shutdown took %s", d)
+	}
+	deadline := time.Now().Add(time.Second)
+	for m.Health() == nil {
+		if time.Now().After(deadline) {
+			t.Fatal("retry loop did not give up after Shutdown deadline")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if n := exp.calls.Load(); n != 1 {
+		t.Fatalf("export calls = %d, want 1", n)
+	}
+}
+
+// blockingExporter 一直等到发送超时
+type blockingExporter struct{}
+
+func (blockingExporter) ExportSpans(ctx context.Context, _ []sdktrace.ReadOnlySpan) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (blockingExporter) Shutdown(context.Context) error { return nil }
+
+// counterByDestination 按destination属性汇总名为name的Counter
+func counterByDestination(rm metricdata.ResourceMetrics, name string) map[string]int64 {
+	out := make(map[string]int64)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			sum, ok := m.Data.(metricdata.Sum[int64])
+			if m.Name != name || !ok {
+				continue
+			}
+			for _, dp := range sum.DataPoints {
+				v, _ := dp.Attributes.Value("destination")
+				out[v.AsString()] += dp.Value
+			}
+		}
+	}
+	return out
+}
+
+// 一个目标卡住时，另一个目标照常收到数据，卡住的目标丢弃、失败计数上升
+func TestMultiExporterSlowDestination(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	prev := otel.GetMeterProvider()
+	otel.SetMeterProvider(mp)
+	defer otel.SetMeterProvider(prev)
+
+	fast := &countingExporter{}
+	m := NewMultiExporter(
+		Destination{Name: "slow", Exporter: blockingExporter{}, QueueSize: 1, Timeout: 300 * time.Millisecond},
+		Destination{Name: "fast", Exporter: fast, QueueSize: 8, Timeout: 100 * time.Millisecond},
+	)
+	const batches = 5
+	for i := 0; i < batches; i++ {
+		if err := m.ExportSpans(context.Background(), spans(2)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// slow的第一批还在等超时，fast应在自己的超时内收到全部批次
+	deadline := time.Now().Add(100 * time.Millisecond)
+	for fast.calls.Load() < batches {
+		if time.Now().After(deadline) {
+			t.Fatalf("fast destination got %d/%d batches while slow is blocked", fast.calls.Load(), batches)
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// 等slow至少有一批超时失败
+	var rm metricdata.ResourceMetrics
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		if err := reader.Collect(context.Background(), &rm); err != nil {
+			t.Fatal(err)
+		}
+		if counterByDestination(rm, "otlp.fanout.spans.failed")["slow"] > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("slow destination never reported a failed batch")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if got := counterByDestination(rm, "otlp.fanout.spans.exported")["fast"]; got != 2*batches {
+		t.Errorf("fast exported %d spans, want %d", got, 2*batches)
+	}
+	// slow的队列只有1个位置，1批在发送、1批排队，其余都被丢弃
+	if got := counterByDestination(rm, "otlp.fanout.spans.dropped"); got["slow"] == 0 || got["fast"] != 0 {
+		t.Errorf("dropped = %v, want only slow", got)
+	}
+	if got := counterByDestination(rm, "otlp.fanout.spans.failed")["fast"]; got != 0 {
+		t.Errorf("fast failed = %d", got)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_ = m.Shutdown(ctx)
+}
+
+// 正常Shutdown后内部的ctx也要取消
+func TestMultiExporterShutdownReleasesContext(t *testing.T) {
+	m := NewMultiExporter(Destination{Name: "a", Exporter: &countingExporter{}})
+	if err := m.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if m.ctx.Err() == nil {
+		t.Error("internal context not cancelled after a clean Shutdown")
+	}
+}
@@ -0,0 +1,186 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// FileExporter 把遥测数据按OTLP JSON逐行写入文件
+// 每行是一个完整的Export*ServiceRequest，和Collector的fileexporter格式一致，可以用replay命令重新发送
+//
+// 同一个FileExporter可以同时作为Trace的otlptrace.Client、Metric的sdkmetric.Exporter使用
+// InitOtlpProvider没有配置Log的Provider，Twin也不产生日志；需要时可以直接调用ExportLogs写入OTLP格式的日志
+// Stop/Shutdown会关闭文件，所以不同信号最好各用一个文件
+type FileExporter struct {
+	mu sync.Mutex
+	w  *rotatingWriter
+
+	temporality sdkmetric.TemporalitySelector
+}
+
+var (
+	_ otlptrace.Client   = (*FileExporter)(nil)
+	_ sdkmetric.Exporter = (*FileExporter)(nil)
+)
+
+// NewFileExporter maxBytes、maxAge为0表示不按该维度切分文件
+func NewFileExporter(path string, maxBytes int64, maxAge time.Duration) (*FileExporter, error) {
+	w := &rotatingWriter{path: path, maxBytes: maxBytes, maxAge: maxAge}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	return &FileExporter{w: w, temporality: sdkmetric.DefaultTemporalitySelector}, nil
+}
+
+func (e *FileExporter) Start(context.Context) error {
+	return nil
+}
+
+func (e *FileExporter) Stop(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w.close()
+}
+
+func (e *FileExporter) UploadTraces(_ context.Context, rs []*tracepb.ResourceSpans) error {
+	return e.write(&coltracepb.ExportTraceServiceRequest{ResourceSpans: rs})
+}
+
+func (e *FileExporter) Temporality(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+	return e.temporality(kind)
+}
+
+func (e *FileExporter) Aggregation(kind sdkmetric.InstrumentKind) sdkmetric.Aggregation {
+	return sdkmetric.DefaultAggregationSelector(kind)
+}
+
+func (e *FileExporter) Export(_ context.Context, rm *metricdata.ResourceMetrics) error {
+	return e.write(&colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{resourceMetricsToPB(rm)},
+	})
+}
+
+func (e *FileExporter) ExportLogs(_ context.Context, rl []*logspb.ResourceLogs) error {
+	return e.write(&collogspb.ExportLogsServiceRequest{ResourceLogs: rl})
+}
+
+func (e *FileExporter) ForceFlush(context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w.sync()
+}
+
+func (e *FileExporter) Shutdown(ctx context.Context) error {
+	return e.Stop(ctx)
+}
+
+func (e *FileExporter) write(m proto.Message) error {
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.w.writeLine(b)
+}
+
+// rotatingWriter 按大小或时间切分文件，旧文件重命名为 path.20060102-150405
+type rotatingWriter struct {
+	path     string
+	maxBytes int64
+	maxAge   time.Duration
+
+	f      *os.File
+	size   int64
+	opened time.Time
+}
+
+func (w *rotatingWriter) open() error {
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("otlp: opening %s: %w", w.path, err)
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f, w.size, w.opened = f, st.Size(), time.Now()
+	return nil
+}
+
+func (w *rotatingWriter) writeLine(b []byte) error {
+	if w.f == nil {
+		return errors.New("otlp: file exporter is closed")
+	}
+	if w.shouldRotate(int64(len(b)) + 1) {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := w.f.Write(append(b, '\n'))
+	w.size += int64(n)
+	return err
+}
+
+func (w *rotatingWriter) shouldRotate(next int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.maxBytes > 0 && w.size+next > w.maxBytes {
+		return true
+	}
+	return w.maxAge > 0 && time.Since(w.opened) > w.maxAge
+}
+
+func (w *rotatingWriter) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.f = nil
+	base := w.path + "." + time.Now().Format("20060102-150405.000")
+	rotated := base
+	// 同一毫秒内切分多次时加序号，不能覆盖上一个文件
+	for i := 1; ; i++ {
+		if _, err := os.Stat(rotated); errors.Is(err, os.ErrNotExist) {
+			break
+		}
+		rotated = fmt.Sprintf("%s.%d", base, i)
+	}
+	if err := os.Rename(w.path, rotated); err != nil {
+		return err
+	}
+	return w.open()
+}
+
+func (w *rotatingWriter) sync() error {
+	if w.f == nil {
+		return nil
+	}
+	return w.f.Sync()
+}
+
+func (w *rotatingWriter) close() error {
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
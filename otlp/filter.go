@@ -0,0 +1,122 @@
+package otlp
+
+import (
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Filter 命中的HTTP请求或gRPC调用不产生Span，Trace上下文仍照常传递(见filter包)
+// 字段之间是"且"，同一字段的多个值是"或"，空字段不参与匹配
+type Filter struct {
+	// HTTP方法(如"GET")或gRPC完整方法名(如"/echo.TestService/SayHello")
+	// 以"/"结尾时按前缀匹配整个服务，如"/grpc.health.v1.Health/"
+	Methods []string
+	// HTTP路径，以"/"结尾时按前缀匹配；gRPC调用没有Route，设置了Routes的Filter不会命中gRPC
+	Routes []string
+	// 请求头或gRPC metadata，值为""时只要求存在
+	Headers map[string]string
+	// 对端IP或CIDR，如"10.0.0.0/8"；gRPC客户端发起调用时还不知道对端，不会命中
+	Peers []string
+}
+
+// Call 用于匹配Filter的请求信息，HTTP与gRPC共用
+type Call struct {
+	Method string
+	Route  string
+	Header func(key string) []string // key不区分大小写
+	Peer   string                    // 可以带端口
+}
+
+// DefaultFilters 默认不追踪健康检查和反射
+var DefaultFilters = []Filter{
+	{Methods: []string{"/grpc.health.v1.Health/"}},
+	{Methods: []string{"/grpc.reflection.v1.ServerReflection/", "/grpc.reflection.v1alpha.ServerReflection/"}},
+	{Routes: []string{"/healthz", "/readyz"}},
+}
+
+func (f Filter) Match(c Call) bool {
+	if len(f.Methods) > 0 && !matchAny(f.Methods, c.Method) {
+		return false
+	}
+	if len(f.Routes) > 0 && (c.Route == "" || !matchAny(f.Routes, c.Route)) {
+		return false
+	}
+	for k, want := range f.Headers {
+		if c.Header == nil {
+			return false
+		}
+		values := c.Header(k)
+		if len(values) == 0 || (want != "" && !contains(values, want)) {
+			return false
+		}
+	}
+	if len(f.Peers) > 0 && !matchPeer(f.Peers, c.Peer) {
+		return false
+	}
+	return true
+}
+
+func matchAny(patterns []string, s string) bool {
+	for _, p := range patterns {
+		if p == s || (strings.HasSuffix(p, "/") && strings.HasPrefix(s, p)) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPeer(peers []string, peer string) bool {
+	if host, _, err := net.SplitHostPort(peer); err == nil {
+		peer = host
+	}
+	addr, err := netip.ParseAddr(peer)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, p := range peers {
+		if prefix, err := netip.ParsePrefix(p); err == nil {
+			if prefix.Contains(addr) {
+				return true
+			}
+		} else if a, err := netip.ParseAddr(p); err == nil && a.Unmap() == addr {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	filtersMu sync.RWMutex
+	filters   = slices.Clone(DefaultFilters) // 调用方改DefaultFilters不影响生效的规则
+)
+
+func setFilters(f []Filter) {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	filters = f
+}
+
+// Excluded 是否命中InitOtlpProvider配置的Filter，未初始化时使用DefaultFilters
+func Excluded(c Call) bool {
+	filtersMu.RLock()
+	defer filtersMu.RUnlock()
+	for _, f := range filters {
+		if f.Match(c) {
+			return true
+		}
+	}
+	return false
+}
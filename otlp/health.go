@@ -0,0 +1,28 @@
+package otlp
+
+import (
+	"sync"
+)
+
+var (
+	healthMu    sync.Mutex
+	healthCheck func() error
+)
+
+func setHealth(f func() error) {
+	healthMu.Lock()
+	defer healthMu.Unlock()
+	healthCheck = f
+}
+
+// Health Trace导出链路的状态：Collector连不上或发送队列快满时返回错误
+// 未调用InitOtlpProvider时返回nil
+func Health() error {
+	healthMu.Lock()
+	f := healthCheck
+	healthMu.Unlock()
+	if f == nil {
+		return nil
+	}
+	return f()
+}
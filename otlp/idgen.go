@@ -0,0 +1,52 @@
+package otlp
+
+import (
+	"context"
+	"hash/fnv"
+	"math/rand"
+	"sync"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// seededIDGenerator 固定种子的ID生成器，同样的种子、同样的调用顺序得到同样的TraceID/SpanID
+// 只用于测试和演示，ID不再随机，不要在生产环境使用
+type seededIDGenerator struct {
+	mu  sync.Mutex
+	rng *rand.Rand
+}
+
+var _ sdktrace.IDGenerator = (*seededIDGenerator)(nil)
+
+// NewIDGenerator service不同的进程即使种子相同也会得到不同的序列，
+// 否则Client与Server会生成相同的SpanID
+func NewIDGenerator(seed int64, service string) sdktrace.IDGenerator {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(service))
+	return &seededIDGenerator{rng: rand.New(rand.NewSource(seed ^ int64(h.Sum64())))}
+}
+
+func (g *seededIDGenerator) NewIDs(context.Context) (trace.TraceID, trace.SpanID) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var tid trace.TraceID
+	var sid trace.SpanID
+	for !tid.IsValid() {
+		_, _ = g.rng.Read(tid[:])
+	}
+	for !sid.IsValid() {
+		_, _ = g.rng.Read(sid[:])
+	}
+	return tid, sid
+}
+
+func (g *seededIDGenerator) NewSpanID(context.Context, trace.TraceID) trace.SpanID {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	var sid trace.SpanID
+	for !sid.IsValid() {
+		_, _ = g.rng.Read(sid[:])
+	}
+	return sid
+}
@@ -0,0 +1,83 @@
+package otlp
+
+import (
+	"context"
+	"math/rand"
+	"testing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ids 依次调用NewIDs、NewSpanID，模拟一个Trace里根Span加子Span
+func ids(g interface {
+	NewIDs(context.Context) (trace.TraceID, trace.SpanID)
+	NewSpanID(context.Context, trace.TraceID) trace.SpanID
+}, n int) []string {
+	ctx := context.Background()
+	var out []string
+	for i := 0; i < n; i++ {
+		tid, sid := g.NewIDs(ctx)
+		out = append(out, tid.String(), sid.String(), g.NewSpanID(ctx, tid).String())
+	}
+	return out
+}
+
+func TestIDGeneratorDeterministic(t *testing.T) {
+	a := ids(NewIDGenerator(42, "svc"), 20)
+	b := ids(NewIDGenerator(42, "svc"), 20)
+	for i := range a {
+		if a[i] != b[i] {
+			t.Fatalf("same seed diverged at #%d: %s != %s", i, a[i], b[i])
+		}
+	}
+}
+
+func TestIDGeneratorDiffers(t *testing.T) {
+	base := ids(NewIDGenerator(42, "svc"), 1)
+	for name, other := range map[string][]string{
+		"seed":    ids(NewIDGenerator(43, "svc"), 1),
+		"service": ids(NewIDGenerator(42, "other"), 1),
+	} {
+		for i := range base {
+			if base[i] == other[i] {
+				t.Errorf("different %s produced the same ID %s", name, base[i])
+			}
+		}
+	}
+}
+
+// zeroSource 前zeros次返回0，读出来的ID全为0
+type zeroSource struct {
+	rand.Source
+	zeros int
+}
+
+func (s *zeroSource) Int63() int64 {
+	if s.zeros > 0 {
+		s.zeros--
+		return 0
+	}
+	return s.Source.Int63()
+}
+
+func TestIDGeneratorNeverZero(t *testing.T) {
+	// rand.Rand.Read每次Int63取7个字节，前3次为0时第一个TraceID全为0，要重新生成
+	g := &seededIDGenerator{rng: rand.New(&zeroSource{Source: rand.NewSource(1), zeros: 3})}
+	tid, sid := g.NewIDs(context.Background())
+	if !tid.IsValid() || !sid.IsValid() {
+		t.Fatalf("got zero IDs %s %s", tid, sid)
+	}
+
+	g = &seededIDGenerator{rng: rand.New(&zeroSource{Source: rand.NewSource(1), zeros: 2})}
+	if sid := g.NewSpanID(context.Background(), tid); !sid.IsValid() {
+		t.Fatalf("got zero span ID %s", sid)
+	}
+
+	gen := NewIDGenerator(0, "")
+	for i := 0; i < 10000; i++ {
+		tid, sid := gen.NewIDs(context.Background())
+		if !tid.IsValid() || !sid.IsValid() || !gen.NewSpanID(context.Background(), tid).IsValid() {
+			t.Fatalf("zero ID at #%d", i)
+		}
+	}
+}
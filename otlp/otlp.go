@@ -3,49 +3,155 @@ package otlp
 import (
 	"context"
 	"fmt"
+
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
 	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	"go.opentelemetry.io/otel/metric"
 	"go.opentelemetry.io/otel/propagation"
 	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
 	"go.opentelemetry.io/otel/sdk/resource"
 	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
-func InitOtlpProvider(ctx context.Context, res *resource.Resource) {
-	client := otlptracehttp.NewClient(otlptracehttp.WithEndpoint("127.0.0.1:4318"), otlptracehttp.WithInsecure())
+func InitOtlpProvider(ctx context.Context, res *resource.Resource, opts ...Option) {
+	cfg := newConfig(opts)
+
+	// 统一到本包的semconv版本，避免后续与探测到的Resource合并时schema冲突
+	// 比本包更新或不认识的版本无法翻译，报告错误后原样使用
+	if upgraded, err := UpgradeResource(res); err != nil {
+		otel.Handle(err)
+	} else {
+		res = upgraded
+	}
+
+	traceClientOpts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	metricClientOpts := []otlpmetrichttp.Option{otlpmetrichttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.TLS != nil {
+		traceClientOpts = append(traceClientOpts, otlptracehttp.WithTLSClientConfig(cfg.TLS))
+		metricClientOpts = append(metricClientOpts, otlpmetrichttp.WithTLSClientConfig(cfg.TLS))
+	} else {
+		traceClientOpts = append(traceClientOpts, otlptracehttp.WithInsecure())
+		metricClientOpts = append(metricClientOpts, otlpmetrichttp.WithInsecure())
+	}
+	// 重试由MultiExporter的Destination负责，关掉otlptracehttp自带的重试(默认最长1分钟)，避免两层叠加
+	traceClientOpts = append(traceClientOpts, otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}))
+	client := otlptracehttp.NewClient(traceClientOpts...)
 	traceExporter, err := otlptrace.New(ctx, client)
 	if err != nil {
-		panic(fmt.Sprintf("creating OTLP trace exporter: %w", err))
+		panic(fmt.Sprintf("creating OTLP trace exporter: %v", err))
 	}
 
+	// 只有Collector时也走MultiExporter，它的发送结果和队列长度用于Health
+	dests := []Destination{{Name: "collector", Exporter: traceExporter, MaxRetries: 3}}
+	// 同时写一份到本地文件，两边各自排队，互不影响
+	if cfg.TraceFile != "" {
+		fileClient, err := NewFileExporter(cfg.TraceFile, cfg.FileMaxBytes, cfg.FileMaxAge)
+		if err != nil {
+			panic(fmt.Sprintf("creating file trace exporter: %v", err))
+		}
+		fileExporter, err := otlptrace.New(ctx, fileClient)
+		if err != nil {
+			panic(fmt.Sprintf("creating file trace exporter: %v", err))
+		}
+		dests = append(dests, Destination{Name: "file", Exporter: fileExporter})
+	}
+	spanExporter := NewMultiExporter(dests...)
+	setHealth(spanExporter.Health)
+
 	// 暂时没有仔细看Collector的代码 Jaeger不支持Metric
-	metricExporter, err := otlpmetrichttp.New(ctx, otlpmetrichttp.WithEndpoint("127.0.0.1:4318"), otlpmetrichttp.WithInsecure())
+	temporality := temporalitySelector(cfg.Temporality)
+	metricExporter, err := otlpmetrichttp.New(ctx, append(metricClientOpts, otlpmetrichttp.WithTemporalitySelector(temporality))...)
 	if err != nil {
-		panic(fmt.Sprintf("creating OTLP trace exporter: %w", err))
+		panic(fmt.Sprintf("creating OTLP trace exporter: %v", err))
+	}
+
+	var traceOpts []sdktrace.TracerProviderOption
+	if cfg.IDSeed != 0 {
+		service, _ := res.Set().Value(semconv.ServiceNameKey)
+		traceOpts = append(traceOpts, sdktrace.WithIDGenerator(NewIDGenerator(cfg.IDSeed, service.AsString())))
 	}
+	sdkTracerProvider := newTraceProvider(spanExporter, res, traceOpts...)
+	onShutdown(sdkTracerProvider.Shutdown)
+	var tracerProvider trace.TracerProvider = sdkTracerProvider
+	if cfg.Clock != nil {
+		setClock(cfg.Clock)
+		tracerProvider = &clockTracerProvider{TracerProvider: tracerProvider, clock: cfg.Clock}
+	}
+	otel.SetTracerProvider(tracerProvider)
+	setFilters(cfg.Filters)
 
-	// 用Prometheus做临时代替
-	//metricExporter, err := prometheus.New()
-	otel.SetTracerProvider(newTraceProvider(traceExporter, res))
-	//otel.SetMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(metricExporter)))
-	otel.SetMeterProvider(newMeterProvider(metricExporter))
+	// 运行时调度延迟、GC停顿是Producer，要挂到每个Reader上
+	readerOpts := []sdkmetric.PeriodicReaderOption{sdkmetric.WithInterval(cfg.MetricInterval)}
+	var promOpts []otelprom.Option
+	if cfg.RuntimeMetrics {
+		for _, producer := range runtimeProducers() {
+			readerOpts = append(readerOpts, sdkmetric.WithProducer(producer))
+			promOpts = append(promOpts, otelprom.WithProducer(producer))
+		}
+	}
+
+	readers := []sdkmetric.Reader{sdkmetric.NewPeriodicReader(metricExporter, readerOpts...)}
+	if cfg.MetricFile != "" {
+		fileExporter, err := NewFileExporter(cfg.MetricFile, cfg.FileMaxBytes, cfg.FileMaxAge)
+		if err != nil {
+			panic(fmt.Sprintf("creating file metric exporter: %v", err))
+		}
+		fileExporter.temporality = temporality
+		readers = append(readers, sdkmetric.NewPeriodicReader(fileExporter, readerOpts...))
+	}
+	// Prometheus拉模式，与OTLP推送并存
+	if cfg.PrometheusAddr != "" {
+		promReader, promServer, err := newPrometheusReader(cfg.PrometheusAddr, promOpts...)
+		if err != nil {
+			panic(err)
+		}
+		readers = append(readers, promReader)
+		// 最后关闭，关闭过程中仍可被抓取
+		defer onShutdown(promServer.Shutdown)
+	}
+	metricViews := views(cfg.Views)
+	sdkMeterProvider := newMeterProvider(res, metricViews, cfg.ExemplarFilter, readers...)
+	onShutdown(sdkMeterProvider.Shutdown)
+	var meterProvider metric.MeterProvider = sdkMeterProvider
+	// 上限按视图之后的属性计数，所以同样要拿到视图
+	if cfg.CardinalityLimit > 0 {
+		meterProvider = newLimitedMeterProvider(meterProvider, cfg.CardinalityLimit, metricViews)
+	}
+	otel.SetMeterProvider(meterProvider)
+	if cfg.RuntimeMetrics {
+		if err := startRuntimeMetrics(meterProvider); err != nil {
+			otel.Handle(err)
+		}
+	}
 
 	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
 }
 
-func newTraceProvider(exp sdktrace.SpanExporter, res *resource.Resource) *sdktrace.TracerProvider {
+func newTraceProvider(exp sdktrace.SpanExporter, res *resource.Resource, opts ...sdktrace.TracerProviderOption) *sdktrace.TracerProvider {
 
-	return sdktrace.NewTracerProvider(
+	return sdktrace.NewTracerProvider(append([]sdktrace.TracerProviderOption{
 		sdktrace.WithBatcher(exp),
 		sdktrace.WithResource(res),
 		//sdktrace.WithSampler(sdktrace.TraceIDRatioBased(0.5)), //概率
 		//tracesdk.WithSampler(tracesdk.ParentBased(tracesdk.TraceIDRatioBased(0.5))),
-	)
+	}, opts...)...)
 }
 
-func newMeterProvider(exp sdkmetric.Exporter) *sdkmetric.MeterProvider {
-	meterProvider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(sdkmetric.NewPeriodicReader(exp)))
+func newMeterProvider(res *resource.Resource, views []sdkmetric.View, filter exemplar.Filter, readers ...sdkmetric.Reader) *sdkmetric.MeterProvider {
+	opts := []sdkmetric.Option{sdkmetric.WithResource(res), sdkmetric.WithView(views...)}
+	// 不配置时SDK读取OTEL_METRICS_EXEMPLAR_FILTER，默认trace_based
+	if filter != nil {
+		opts = append(opts, sdkmetric.WithExemplarFilter(filter))
+	}
+	for _, r := range readers {
+		opts = append(opts, sdkmetric.WithReader(r))
+	}
+	meterProvider := sdkmetric.NewMeterProvider(opts...)
 	return meterProvider
 }
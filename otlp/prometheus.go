@@ -0,0 +1,48 @@
+package otlp
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel"
+	otelprom "go.opentelemetry.io/otel/exporters/prometheus"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+)
+
+// newPrometheusReader 创建一个拉模式的Reader，并在addr上提供/metrics
+// 指标名、单位后缀(_seconds、_bytes)、Counter的_total都由exporter按规范转换，
+// Resource属性以target_info暴露，exemplar需要抓取端协商OpenMetrics格式才会输出
+// addr的端口为0时，返回的Server.Addr是实际监听的地址
+// 返回的Server在Shutdown时关闭
+func newPrometheusReader(addr string, opts ...otelprom.Option) (sdkmetric.Reader, *http.Server, error) {
+	// 使用独立的Registry，避免和默认Registry里的Go运行时指标混在一起
+	registry := prometheus.NewRegistry()
+	// 每条序列已带otel_scope_name/version标签，不再单独输出otel_scope_info；
+	// 否则runtime的Producer与Instrument同名scope会导致otel_scope_info重复而抓取失败
+	exporter, err := otelprom.New(append(opts, otelprom.WithRegisterer(registry), otelprom.WithoutScopeInfo())...)
+	if err != nil {
+		return nil, nil, fmt.Errorf("creating prometheus exporter: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.HandlerFor(registry, promhttp.HandlerOpts{EnableOpenMetrics: true}))
+	srv := &http.Server{Addr: addr, Handler: mux}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		// 抓取端口被占用不影响服务本身，推送模式照常工作
+		otel.Handle(fmt.Errorf("listening prometheus metrics on %s: %w", addr, err))
+		return exporter, srv, nil
+	}
+	srv.Addr = ln.Addr().String()
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			otel.Handle(fmt.Errorf("serving prometheus metrics on %s: %w", addr, err))
+		}
+	}()
+
+	return exporter, srv, nil
+}
@@ -0,0 +1,96 @@
+package otlp
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/exemplar"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestPrometheusScrape(t *testing.T) {
+	reader, srv, err := newPrometheusReader("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer srv.Shutdown(context.Background())
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceName("prom-test"),
+		attribute.String("deployment.environment.name", "test"),
+	)
+	mp := newMeterProvider(res, nil, exemplar.AlwaysOnFilter, reader)
+	defer mp.Shutdown(context.Background())
+
+	meter := mp.Meter("prom-test")
+	counter, err := meter.Int64Counter("requests", metric.WithUnit("{request}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := meter.Float64Histogram("latency", metric.WithUnit("s"), metric.WithExplicitBucketBoundaries(0.1, 1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 带上采样的SpanContext，exemplar里应出现这个trace_id
+	sc := trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID:    trace.TraceID{0x0a, 0x0b},
+		SpanID:     trace.SpanID{0x01},
+		TraceFlags: trace.FlagsSampled,
+	})
+	ctx := trace.ContextWithSpanContext(context.Background(), sc)
+	counter.Add(ctx, 3)
+	hist.Record(ctx, 0.5)
+
+	scrape := func(accept string) string {
+		req, _ := http.NewRequest(http.MethodGet, "http://"+srv.Addr+"/metrics", nil)
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("scrape: %s\n%s", resp.Status, b)
+		}
+		return string(b)
+	}
+
+	body := scrape("application/openmetrics-text; version=1.0.0")
+	for _, want := range []string{
+		"requests_total 3.0 # {",
+		"latency_seconds_bucket{",
+		"latency_seconds_count 1",
+		`target_info{deployment_environment_name="test",service_name="prom-test"}`,
+		`trace_id="0a0b0000000000000000000000000000"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("OpenMetrics scrape missing %q:\n%s", want, body)
+		}
+	}
+
+	// 文本格式不输出exemplar
+	if text := scrape(""); strings.Contains(text, "trace_id=") {
+		t.Errorf("text format scrape has exemplars:\n%s", text)
+	}
+}
+
+func TestPrometheusAddrEnv(t *testing.T) {
+	t.Setenv(PrometheusAddrEnv, ":19464")
+	if got := newConfig([]Option{WithPrometheus(":9464")}).PrometheusAddr; got != ":19464" {
+		t.Errorf("PrometheusAddr = %q, want :19464", got)
+	}
+	t.Setenv(PrometheusAddrEnv, "off")
+	if got := newConfig([]Option{WithPrometheus(":9464")}).PrometheusAddr; got != "" {
+		t.Errorf("PrometheusAddr = %q, want disabled", got)
+	}
+}
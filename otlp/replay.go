@@ -0,0 +1,219 @@
+package otlp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// Replay 读取FileExporter写出的文件，按OTLP/HTTP(protobuf)重新发送到endpoint
+// rebase为true时，所有时间戳整体平移，使最早的时间戳对齐到当前时间
+func Replay(ctx context.Context, endpoint string, rebase bool, paths ...string) error {
+	return ReplayTLS(ctx, endpoint, nil, rebase, paths...)
+}
+
+// ReplayTLS cfg非nil时通过HTTPS发送，见tlsutil.ClientConfig
+func ReplayTLS(ctx context.Context, endpoint string, cfg *tls.Config, rebase bool, paths ...string) error {
+	var reqs []proto.Message
+	for _, p := range paths {
+		r, err := readRequests(p)
+		if err != nil {
+			return err
+		}
+		reqs = append(reqs, r...)
+	}
+
+	if rebase {
+		var earliest uint64
+		for _, r := range reqs {
+			eachTimestamp(r, func(t *uint64) {
+				if earliest == 0 || *t < earliest {
+					earliest = *t
+				}
+			})
+		}
+		if earliest != 0 {
+			offset := uint64(time.Now().UnixNano()) - earliest
+			for _, r := range reqs {
+				eachTimestamp(r, func(t *uint64) { *t += offset })
+			}
+		}
+	}
+
+	client, scheme := http.DefaultClient, "http://"
+	if cfg != nil {
+		t := http.DefaultTransport.(*http.Transport).Clone()
+		t.TLSClientConfig = cfg
+		client, scheme = &http.Client{Transport: t}, "https://"
+	}
+	for _, r := range reqs {
+		if err := send(ctx, client, scheme+endpoint, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readRequests(path string) ([]proto.Message, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var out []proto.Message
+	sc := bufio.NewScanner(f)
+	sc.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; sc.Scan(); line++ {
+		b := sc.Bytes()
+		if len(bytes.TrimSpace(b)) == 0 {
+			continue
+		}
+		// 根据顶层字段判断是哪种信号
+		var probe map[string]json.RawMessage
+		if err := json.Unmarshal(b, &probe); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		var m proto.Message
+		switch {
+		case probe["resourceSpans"] != nil:
+			m = &coltracepb.ExportTraceServiceRequest{}
+		case probe["resourceMetrics"] != nil:
+			m = &colmetricpb.ExportMetricsServiceRequest{}
+		case probe["resourceLogs"] != nil:
+			m = &collogspb.ExportLogsServiceRequest{}
+		default:
+			return nil, fmt.Errorf("%s:%d: unknown OTLP signal", path, line)
+		}
+		if err := protojson.Unmarshal(b, m); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		out = append(out, m)
+	}
+	return out, sc.Err()
+}
+
+// send base为"http(s)://host:port"
+func send(ctx context.Context, client *http.Client, base string, m proto.Message) error {
+	var urlPath string
+	switch m.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		urlPath = "/v1/traces"
+	case *colmetricpb.ExportMetricsServiceRequest:
+		urlPath = "/v1/metrics"
+	case *collogspb.ExportLogsServiceRequest:
+		urlPath = "/v1/logs"
+	}
+	body, err := proto.Marshal(m)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, base+urlPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("otlp: replay %s: %s: %s", urlPath, resp.Status, msg)
+	}
+	return nil
+}
+
+// eachTimestamp 遍历请求中所有非零时间戳
+func eachTimestamp(m proto.Message, fn func(*uint64)) {
+	visit := func(t *uint64) {
+		if *t != 0 {
+			fn(t)
+		}
+	}
+	switch r := m.(type) {
+	case *coltracepb.ExportTraceServiceRequest:
+		for _, rs := range r.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					visit(&s.StartTimeUnixNano)
+					visit(&s.EndTimeUnixNano)
+					for _, e := range s.Events {
+						visit(&e.TimeUnixNano)
+					}
+				}
+			}
+		}
+	case *collogspb.ExportLogsServiceRequest:
+		for _, rl := range r.ResourceLogs {
+			for _, sl := range rl.ScopeLogs {
+				for _, l := range sl.LogRecords {
+					visit(&l.TimeUnixNano)
+					visit(&l.ObservedTimeUnixNano)
+				}
+			}
+		}
+	case *colmetricpb.ExportMetricsServiceRequest:
+		for _, rm := range r.ResourceMetrics {
+			for _, sm := range rm.ScopeMetrics {
+				for _, mt := range sm.Metrics {
+					eachMetricTimestamp(mt, visit)
+				}
+			}
+		}
+	}
+}
+
+func eachMetricTimestamp(m *metricpb.Metric, visit func(*uint64)) {
+	exemplars := func(exs []*metricpb.Exemplar) {
+		for _, e := range exs {
+			visit(&e.TimeUnixNano)
+		}
+	}
+	switch d := m.Data.(type) {
+	case *metricpb.Metric_Gauge:
+		for _, dp := range d.Gauge.DataPoints {
+			visit(&dp.StartTimeUnixNano)
+			visit(&dp.TimeUnixNano)
+			exemplars(dp.Exemplars)
+		}
+	case *metricpb.Metric_Sum:
+		for _, dp := range d.Sum.DataPoints {
+			visit(&dp.StartTimeUnixNano)
+			visit(&dp.TimeUnixNano)
+			exemplars(dp.Exemplars)
+		}
+	case *metricpb.Metric_Histogram:
+		for _, dp := range d.Histogram.DataPoints {
+			visit(&dp.StartTimeUnixNano)
+			visit(&dp.TimeUnixNano)
+			exemplars(dp.Exemplars)
+		}
+	case *metricpb.Metric_ExponentialHistogram:
+		for _, dp := range d.ExponentialHistogram.DataPoints {
+			visit(&dp.StartTimeUnixNano)
+			visit(&dp.TimeUnixNano)
+			exemplars(dp.Exemplars)
+		}
+	case *metricpb.Metric_Summary:
+		for _, dp := range d.Summary.DataPoints {
+			visit(&dp.StartTimeUnixNano)
+			visit(&dp.TimeUnixNano)
+		}
+	}
+}
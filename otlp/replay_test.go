@@ -0,0 +1,259 @@
+package otlp
+
+import (
+	"context"
+	"crypto/tls"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// 固定的原始时间，便于和重放后的时间戳比较
+var recorded = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+func newTestCollector(t *testing.T) *Collector {
+	t.Helper()
+	col, err := NewCollector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = col.Shutdown(context.Background()) })
+	return col
+}
+
+func testSpans(name string, start time.Time) []*tracepb.ResourceSpans {
+	return []*tracepb.ResourceSpans{{ScopeSpans: []*tracepb.ScopeSpans{{Spans: []*tracepb.Span{{
+		TraceId: make([]byte, 16), SpanId: make([]byte, 8), Name: name,
+		StartTimeUnixNano: uint64(start.UnixNano()),
+		EndTimeUnixNano:   uint64(start.Add(time.Second).UnixNano()),
+	}}}}}}
+}
+
+// writeSpans 每个名字写一行，返回所有落盘的文件(包括切分出去的)
+func writeSpans(t *testing.T, maxBytes int64, maxAge time.Duration, gap time.Duration, names ...string) []string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	fe, err := NewFileExporter(path, maxBytes, maxAge)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range names {
+		if err := fe.UploadTraces(context.Background(), testSpans(n, recorded)); err != nil {
+			t.Fatal(err)
+		}
+		time.Sleep(gap)
+	}
+	if err := fe.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	files, err := filepath.Glob(path + "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func spanNames(rss []*tracepb.ResourceSpans) map[string]bool {
+	out := make(map[string]bool)
+	for _, rs := range rss {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				out[s.Name] = true
+			}
+		}
+	}
+	return out
+}
+
+func TestReplayRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.Background()
+	res := resource.NewSchemaless()
+
+	traces, err := NewFileExporter(filepath.Join(dir, "traces.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := traces.UploadTraces(ctx, testSpans("replayed", recorded)); err != nil {
+		t.Fatal(err)
+	}
+
+	metrics, err := NewFileExporter(filepath.Join(dir, "metrics.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := metrics.Export(ctx, &metricdata.ResourceMetrics{
+		Resource: res,
+		ScopeMetrics: []metricdata.ScopeMetrics{{
+			Scope: instrumentation.Scope{Name: "test"},
+			Metrics: []metricdata.Metrics{{
+				Name: "requests",
+				Data: metricdata.Sum[int64]{
+					Temporality: metricdata.CumulativeTemporality,
+					IsMonotonic: true,
+					DataPoints:  []metricdata.DataPoint[int64]{{StartTime: recorded, Time: recorded.Add(time.Second), Value: 7}},
+				},
+			}},
+		}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	logs, err := NewFileExporter(filepath.Join(dir, "logs.jsonl"), 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := logs.ExportLogs(ctx, []*logspb.ResourceLogs{{ScopeLogs: []*logspb.ScopeLogs{{LogRecords: []*logspb.LogRecord{{
+		TimeUnixNano: uint64(recorded.UnixNano()), SeverityText: "INFO",
+	}}}}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, fe := range []*FileExporter{traces, metrics, logs} {
+		if err := fe.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	col := newTestCollector(t)
+	files := []string{filepath.Join(dir, "traces.jsonl"), filepath.Join(dir, "metrics.jsonl"), filepath.Join(dir, "logs.jsonl")}
+	if err := Replay(ctx, col.Endpoint(), false, files...); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := spanNames(col.Spans()); !got["replayed"] || len(got) != 1 {
+		t.Errorf("spans %v, want [replayed]", got)
+	}
+	ms := col.Metrics()
+	if len(ms) != 1 || ms[0].ScopeMetrics[0].Metrics[0].Name != "requests" {
+		t.Fatalf("metrics %v", ms)
+	}
+	if v := ms[0].ScopeMetrics[0].Metrics[0].GetSum().DataPoints[0].GetAsInt(); v != 7 {
+		t.Errorf("metric value %d, want 7", v)
+	}
+	ls := col.Logs()
+	if len(ls) != 1 || ls[0].ScopeLogs[0].LogRecords[0].SeverityText != "INFO" {
+		t.Errorf("logs %v", ls)
+	}
+}
+
+func TestReplaySizeRotation(t *testing.T) {
+	// 每行都超过maxBytes，每次写入都会切分
+	names := []string{"a", "b", "c", "d"}
+	files := writeSpans(t, 1, 0, 0, names...)
+	if len(files) != len(names) {
+		t.Fatalf("got %d files %v, want %d", len(files), files, len(names))
+	}
+
+	col := newTestCollector(t)
+	if err := Replay(context.Background(), col.Endpoint(), false, files...); err != nil {
+		t.Fatal(err)
+	}
+	got := spanNames(col.Spans())
+	for _, n := range names {
+		if !got[n] {
+			t.Errorf("span %q lost across rotation, got %v", n, got)
+		}
+	}
+}
+
+func TestReplayTimeRotation(t *testing.T) {
+	names := []string{"a", "b", "c"}
+	files := writeSpans(t, 0, 10*time.Millisecond, 20*time.Millisecond, names...)
+	if len(files) != len(names) {
+		t.Fatalf("got %d files %v, want %d", len(files), files, len(names))
+	}
+
+	col := newTestCollector(t)
+	if err := Replay(context.Background(), col.Endpoint(), false, files...); err != nil {
+		t.Fatal(err)
+	}
+	if got := spanNames(col.Spans()); len(got) != len(names) {
+		t.Errorf("spans %v, want %v", got, names)
+	}
+}
+
+func TestReplayTimestamps(t *testing.T) {
+	files := writeSpans(t, 0, 0, 0, "s")
+	start, end := uint64(recorded.UnixNano()), uint64(recorded.Add(time.Second).UnixNano())
+
+	for _, tc := range []struct {
+		name   string
+		rebase bool
+	}{
+		{"original", false},
+		{"rebased", true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			col := newTestCollector(t)
+			before := uint64(time.Now().UnixNano())
+			if err := Replay(context.Background(), col.Endpoint(), tc.rebase, files...); err != nil {
+				t.Fatal(err)
+			}
+			after := uint64(time.Now().UnixNano())
+
+			s := col.Spans()[0].ScopeSpans[0].Spans[0]
+			if !tc.rebase {
+				if s.StartTimeUnixNano != start || s.EndTimeUnixNano != end {
+					t.Errorf("timestamps %d-%d, want original %d-%d", s.StartTimeUnixNano, s.EndTimeUnixNano, start, end)
+				}
+				return
+			}
+			// 最早的时间戳对齐到重放时刻，间隔保持不变
+			if s.StartTimeUnixNano < before || s.StartTimeUnixNano > after {
+				t.Errorf("rebased start %d not within replay window [%d, %d]", s.StartTimeUnixNano, before, after)
+			}
+			if d := s.EndTimeUnixNano - s.StartTimeUnixNano; d != end-start {
+				t.Errorf("duration %d, want %d", d, end-start)
+			}
+		})
+	}
+}
+
+func TestReplayTLS(t *testing.T) {
+	ca, err := tlsutil.NewCA("replay test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	serverCert, err := ca.Issue("localhost", "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := ca.Issue("replay")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 要求客户端证书(mTLS)
+	col, err := NewTLSCollector("127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{serverCert},
+		ClientCAs:    ca.Pool(),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer col.Shutdown(context.Background())
+	files := writeSpans(t, 0, 0, 0, "replayed")
+
+	ctx := context.Background()
+	if err := Replay(ctx, col.Endpoint(), false, files...); err == nil {
+		t.Error("plain http replay to a TLS collector: want error")
+	}
+	if err := ReplayTLS(ctx, col.Endpoint(), &tls.Config{RootCAs: ca.Pool()}, false, files...); err == nil {
+		t.Error("replay without a client certificate: want error")
+	}
+	cfg := &tls.Config{RootCAs: ca.Pool(), Certificates: []tls.Certificate{clientCert}}
+	if err := ReplayTLS(ctx, col.Endpoint(), cfg, false, files...); err != nil {
+		t.Fatal(err)
+	}
+	if got := spanNames(col.Spans()); len(got) != 1 || !got["replayed"] {
+		t.Errorf("collector got %v", got)
+	}
+}
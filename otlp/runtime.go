@@ -0,0 +1,159 @@
+package otlp
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/prometheus/procfs"
+	"go.opentelemetry.io/contrib/instrumentation/runtime"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// DeprecatedRuntimeMetricsEnv contrib/runtime未设置时输出旧的process.runtime.go.*指标，设为"false"才输出semconv命名的go.*指标
+// WithRuntimeMetrics在该变量未设置时替进程设为"false"；需要旧指标的服务显式设为"true"
+const DeprecatedRuntimeMetricsEnv = "OTEL_GO_X_DEPRECATED_RUNTIME_METRICS"
+
+// startRuntimeMetrics Go运行时指标(go.goroutine.count、go.memory.*、go.config.gogc等)由contrib/runtime提供，
+// 直方图类的指标是Producer，需要挂到每个Reader上，见runtimeProducers
+func startRuntimeMetrics(mp metric.MeterProvider) error {
+	// contrib/runtime只在Start时读这个环境变量
+	if _, ok := os.LookupEnv(DeprecatedRuntimeMetricsEnv); !ok {
+		_ = os.Setenv(DeprecatedRuntimeMetricsEnv, "false")
+	}
+	if err := runtime.Start(runtime.WithMeterProvider(mp)); err != nil {
+		return fmt.Errorf("starting runtime metrics: %w", err)
+	}
+	return startProcessMetrics(mp)
+}
+
+// startProcessMetrics 进程级指标从/proc读取，非Linux环境直接跳过
+func startProcessMetrics(mp metric.MeterProvider) error {
+	proc, err := procfs.Self()
+	if err != nil {
+		return nil
+	}
+
+	meter := mp.Meter("github.com/dextercai/OpenTelemetry-Golang-Playground/otlp")
+	cpuTime, err := meter.Float64ObservableCounter("process.cpu.time",
+		metric.WithUnit("s"), metric.WithDescription("Total CPU seconds broken down by different CPU modes."))
+	if err != nil {
+		return err
+	}
+	memUsage, err := meter.Int64ObservableUpDownCounter("process.memory.usage",
+		metric.WithUnit("By"), metric.WithDescription("The amount of physical memory in use."))
+	if err != nil {
+		return err
+	}
+	memVirtual, err := meter.Int64ObservableUpDownCounter("process.memory.virtual",
+		metric.WithUnit("By"), metric.WithDescription("The amount of committed virtual memory."))
+	if err != nil {
+		return err
+	}
+	openFDs, err := meter.Int64ObservableUpDownCounter("process.open_file_descriptor.count",
+		metric.WithUnit("{count}"), metric.WithDescription("Number of file descriptors in use by the process."))
+	if err != nil {
+		return err
+	}
+
+	user := metric.WithAttributes(attribute.String("cpu.mode", "user"))
+	system := metric.WithAttributes(attribute.String("cpu.mode", "system"))
+	_, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		stat, err := proc.Stat()
+		if err != nil {
+			return err
+		}
+		// utime/stime单位是时钟滴答，换算交给procfs的CPUTime，再按两者的比例拆分
+		if ticks := stat.UTime + stat.STime; ticks > 0 {
+			perTick := stat.CPUTime() / float64(ticks)
+			o.ObserveFloat64(cpuTime, float64(stat.UTime)*perTick, user)
+			o.ObserveFloat64(cpuTime, float64(stat.STime)*perTick, system)
+		}
+		o.ObserveInt64(memUsage, int64(stat.ResidentMemory()))
+		o.ObserveInt64(memVirtual, int64(stat.VirtualMemory()))
+		if n, err := proc.FileDescriptorsLen(); err == nil {
+			o.ObserveInt64(openFDs, int64(n))
+		}
+		return nil
+	}, cpuTime, memUsage, memVirtual, openFDs)
+	return err
+}
+
+// runtimeProducers 调度延迟go.schedule.duration来自contrib/runtime；
+// semconv模式下contrib/runtime不再输出GC停顿，go.gc.pause.duration由gcPauseProducer补上
+func runtimeProducers() []sdkmetric.Producer {
+	return []sdkmetric.Producer{runtime.NewProducer(), newGCPauseProducer()}
+}
+
+const gcPausesMetric = "/gc/pauses:seconds"
+
+// gcPauseProducer 把runtime/metrics的GC停顿直方图原样转成累积的OTel直方图
+type gcPauseProducer struct {
+	start time.Time
+
+	mu     sync.Mutex
+	sample []metrics.Sample
+}
+
+func newGCPauseProducer() *gcPauseProducer {
+	return &gcPauseProducer{
+		start:  time.Now(),
+		sample: []metrics.Sample{{Name: gcPausesMetric}},
+	}
+}
+
+func (p *gcPauseProducer) Produce(context.Context) ([]metricdata.ScopeMetrics, error) {
+	p.mu.Lock()
+	metrics.Read(p.sample)
+	v := p.sample[0].Value
+	p.mu.Unlock()
+	if v.Kind() != metrics.KindFloat64Histogram {
+		return nil, fmt.Errorf("runtime metric %s is not supported", gcPausesMetric)
+	}
+
+	dp := gcPauseDataPoint(v.Float64Histogram())
+	dp.StartTime, dp.Time = p.start, time.Now()
+	return []metricdata.ScopeMetrics{{
+		Scope: instrumentation.Scope{Name: "github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"},
+		Metrics: []metricdata.Metrics{{
+			Name:        "go.gc.pause.duration",
+			Description: "Distribution of individual GC-related stop-the-world pause latencies.",
+			Unit:        "s",
+			Data: metricdata.Histogram[float64]{
+				Temporality: metricdata.CumulativeTemporality,
+				DataPoints:  []metricdata.HistogramDataPoint[float64]{dp},
+			},
+		}},
+	}}, nil
+}
+
+// gcPauseDataPoint runtime的Buckets包含下界，OTel的Bounds只要上界，末尾的+Inf是隐含的
+// Sum按每个桶的下界估算，偏小
+func gcPauseDataPoint(h *metrics.Float64Histogram) metricdata.HistogramDataPoint[float64] {
+	bounds := h.Buckets[1:]
+	counts := append([]uint64(nil), h.Counts...)
+	if bounds[len(bounds)-1] == math.Inf(1) {
+		bounds = bounds[:len(bounds)-1]
+	} else {
+		counts = append(counts, 0)
+	}
+	dp := metricdata.HistogramDataPoint[float64]{
+		Bounds:       append([]float64(nil), bounds...),
+		BucketCounts: counts,
+	}
+	for i, c := range h.Counts {
+		dp.Count += c
+		if lower := h.Buckets[i]; c > 0 && !math.IsInf(lower, -1) {
+			dp.Sum += lower * float64(c)
+		}
+	}
+	return dp
+}
@@ -0,0 +1,72 @@
+package otlp
+
+import (
+	"context"
+	"os"
+	goruntime "runtime"
+	"strings"
+	"testing"
+
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// 调用方没有设置DeprecatedRuntimeMetricsEnv时输出semconv命名的指标，GC停顿由本包补上
+func TestRuntimeMetricNames(t *testing.T) {
+	t.Setenv(DeprecatedRuntimeMetricsEnv, "")
+	os.Unsetenv(DeprecatedRuntimeMetricsEnv)
+
+	var opts []sdkmetric.ManualReaderOption
+	for _, p := range runtimeProducers() {
+		opts = append(opts, sdkmetric.WithProducer(p))
+	}
+	reader := sdkmetric.NewManualReader(opts...)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer mp.Shutdown(context.Background())
+	if err := startRuntimeMetrics(mp); err != nil {
+		t.Fatal(err)
+	}
+	goruntime.GC()
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]metricdata.Metrics)
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m
+		}
+	}
+
+	for _, name := range []string{
+		"go.goroutine.count",
+		"go.memory.used",
+		"go.memory.gc.goal",
+		"go.gc.pause.duration",
+		"go.schedule.duration",
+		"process.open_file_descriptor.count",
+		"process.memory.usage",
+	} {
+		if _, ok := got[name]; !ok {
+			t.Errorf("missing %s", name)
+		}
+	}
+	for name := range got {
+		if strings.HasPrefix(name, "process.runtime.") {
+			t.Errorf("deprecated metric %s is still produced", name)
+		}
+	}
+
+	m, ok := got["go.gc.pause.duration"]
+	if !ok {
+		return
+	}
+	pauses := m.Data.(metricdata.Histogram[float64]).DataPoints[0]
+	if pauses.Count == 0 {
+		t.Error("go.gc.pause.duration recorded no pauses after runtime.GC")
+	}
+	if len(pauses.BucketCounts) != len(pauses.Bounds)+1 {
+		t.Errorf("%d buckets for %d bounds", len(pauses.BucketCounts), len(pauses.Bounds))
+	}
+}
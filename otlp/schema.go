@@ -0,0 +1,95 @@
+package otlp
+
+import (
+	"fmt"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const schemaURLPrefix = "https://opentelemetry.io/schemas/"
+
+// resourceSchemaChanges 各版本相对上一版本的Resource属性重命名，按版本升序排列
+// 只收录了Resource上的重命名，Span/Metric属性的变化不在这里处理
+var resourceSchemaChanges = []struct {
+	version string
+	renames map[attribute.Key]attribute.Key
+}{
+	{"1.4.0", nil},
+	{"1.5.0", nil},
+	{"1.6.1", nil},
+	{"1.7.0", nil},
+	{"1.8.0", nil},
+	{"1.9.0", nil},
+	{"1.10.0", nil},
+	{"1.11.0", nil},
+	{"1.12.0", nil},
+	{"1.13.0", nil},
+	{"1.14.0", nil},
+	{"1.15.0", nil},
+	{"1.16.0", nil},
+	{"1.17.0", nil},
+	{"1.18.0", nil},
+	{"1.19.0", map[attribute.Key]attribute.Key{"browser.user_agent": "user_agent.original"}},
+	{"1.20.0", nil},
+	{"1.21.0", nil},
+	{"1.22.0", map[attribute.Key]attribute.Key{"telemetry.auto.version": "telemetry.distro.version"}},
+	{"1.23.0", nil},
+	{"1.23.1", nil},
+	{"1.24.0", nil},
+	{"1.25.0", nil},
+	{"1.26.0", nil},
+}
+
+// UpgradeResource 把res的属性按翻译表升级到本包使用的semconv版本
+// 没有schema URL的Resource原样返回；版本不认识(比如比本包更新)时返回错误
+func UpgradeResource(res *resource.Resource) (*resource.Resource, error) {
+	from := res.SchemaURL()
+	if from == "" || from == semconv.SchemaURL {
+		return res, nil
+	}
+
+	fromVersion := strings.TrimPrefix(from, schemaURLPrefix)
+	start := -1
+	for i, c := range resourceSchemaChanges {
+		if c.version == fromVersion {
+			start = i
+			break
+		}
+	}
+	if !strings.HasPrefix(from, schemaURLPrefix) || start < 0 {
+		return nil, fmt.Errorf("otlp: cannot translate resource schema %s to %s: unknown schema version", from, semconv.SchemaURL)
+	}
+
+	attrs := res.Attributes()
+	for _, c := range resourceSchemaChanges[start+1:] {
+		for i, kv := range attrs {
+			if to, ok := c.renames[kv.Key]; ok {
+				attrs[i] = attribute.KeyValue{Key: to, Value: kv.Value}
+			}
+		}
+	}
+	return resource.NewWithAttributes(semconv.SchemaURL, attrs...), nil
+}
+
+// MergeResources 与resource.Merge相同，后面的优先
+// 区别是schema不一致时先尝试升级，升级失败返回说明是哪个Resource、哪个版本的错误，而不是只报ErrSchemaURLConflict
+func MergeResources(rs ...*resource.Resource) (*resource.Resource, error) {
+	merged := resource.Empty()
+	for i, r := range rs {
+		if r == nil {
+			continue
+		}
+		upgraded, err := UpgradeResource(r)
+		if err != nil {
+			return nil, fmt.Errorf("merging resource #%d: %w", i, err)
+		}
+		merged, err = resource.Merge(merged, upgraded)
+		if err != nil {
+			return nil, fmt.Errorf("merging resource #%d (%s): %w", i, upgraded.SchemaURL(), err)
+		}
+	}
+	return merged, nil
+}
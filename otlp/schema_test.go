@@ -0,0 +1,179 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"log"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+func TestUpgradeResource(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		in      *resource.Resource
+		want    []attribute.KeyValue
+		wantURL string
+		wantErr bool
+	}{
+		{
+			name:    "same schema",
+			in:      resource.NewWithAttributes(semconv.SchemaURL, attribute.String("telemetry.distro.version", "1")),
+			want:    []attribute.KeyValue{attribute.String("telemetry.distro.version", "1")},
+			wantURL: semconv.SchemaURL,
+		},
+		{
+			// 1.19.0和1.22.0各有一次重命名，都要应用
+			name: "older schema",
+			in: resource.NewWithAttributes(schemaURLPrefix+"1.18.0",
+				attribute.String("browser.user_agent", "Mozilla/5.0"),
+				attribute.String("telemetry.auto.version", "0.1"),
+				attribute.String("service.name", "svc"),
+			),
+			want: []attribute.KeyValue{
+				attribute.String("user_agent.original", "Mozilla/5.0"),
+				attribute.String("telemetry.distro.version", "0.1"),
+				attribute.String("service.name", "svc"),
+			},
+			wantURL: semconv.SchemaURL,
+		},
+		{
+			// 1.22.0之后的版本不再翻译telemetry.auto.version
+			name:    "after rename",
+			in:      resource.NewWithAttributes(schemaURLPrefix+"1.22.0", attribute.String("telemetry.auto.version", "0.1")),
+			want:    []attribute.KeyValue{attribute.String("telemetry.auto.version", "0.1")},
+			wantURL: semconv.SchemaURL,
+		},
+		{
+			name:    "newer schema",
+			in:      resource.NewWithAttributes(schemaURLPrefix+"1.27.0", attribute.String("service.name", "svc")),
+			wantErr: true,
+		},
+		{
+			name:    "unknown schema",
+			in:      resource.NewWithAttributes("https://example.com/schemas/1.0.0", attribute.String("service.name", "svc")),
+			wantErr: true,
+		},
+		{
+			name: "empty schema",
+			in:   resource.NewSchemaless(attribute.String("service.name", "svc")),
+			want: []attribute.KeyValue{attribute.String("service.name", "svc")},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := UpgradeResource(tc.in)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("got %v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.SchemaURL() != tc.wantURL {
+				t.Errorf("schema %q, want %q", got.SchemaURL(), tc.wantURL)
+			}
+			if want := attribute.NewSet(tc.want...); !got.Set().Equals(&want) {
+				t.Errorf("attributes %v, want %v", got.Attributes(), tc.want)
+			}
+		})
+	}
+}
+
+func TestMergeResources(t *testing.T) {
+	old := resource.NewWithAttributes(schemaURLPrefix+"1.21.0",
+		attribute.String("telemetry.auto.version", "0.1"), attribute.String("service.name", "old"))
+	cur := resource.NewWithAttributes(semconv.SchemaURL, attribute.String("service.name", "new"))
+
+	// resource.Merge遇到不同schema直接报冲突
+	if _, err := resource.Merge(old, cur); !errors.Is(err, resource.ErrSchemaURLConflict) {
+		t.Fatalf("resource.Merge = %v, want schema conflict", err)
+	}
+	got, err := MergeResources(old, nil, cur)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.SchemaURL() != semconv.SchemaURL {
+		t.Errorf("schema %q", got.SchemaURL())
+	}
+	if v, _ := got.Set().Value("service.name"); v.AsString() != "new" {
+		t.Errorf("service.name = %q, later resource should win", v.AsString())
+	}
+	if v, _ := got.Set().Value("telemetry.distro.version"); v.AsString() != "0.1" {
+		t.Errorf("telemetry.distro.version = %q, want upgraded from telemetry.auto.version", v.AsString())
+	}
+
+	newer := resource.NewWithAttributes(schemaURLPrefix+"1.27.0", attribute.String("service.name", "x"))
+	if _, err := MergeResources(cur, newer); err == nil {
+		t.Error("merging a newer schema: want error")
+	}
+}
+
+// 调用方给的属性覆盖OTEL_RESOURCE_ATTRIBUTES，再覆盖探测到的属性
+func TestNewResourceMerge(t *testing.T) {
+	t.Setenv("OTEL_RESOURCE_ATTRIBUTES", "service.name=from-env,deployment.environment=test")
+	res, err := ResourceDetector{Root: fakeRoot(t, podInfo()), Getenv: noEnv}.
+		NewResource(context.Background(), semconv.ServiceName("svc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.SchemaURL() != semconv.SchemaURL {
+		t.Errorf("schema %q", res.SchemaURL())
+	}
+	for key, want := range map[attribute.Key]string{
+		semconv.ServiceNameKey:           "svc",
+		semconv.DeploymentEnvironmentKey: "test",
+		semconv.K8SPodNameKey:            "web-7d9f",
+		semconv.TelemetrySDKNameKey:      "opentelemetry",
+	} {
+		if v, _ := res.Set().Value(key); v.AsString() != want {
+			t.Errorf("%s = %q, want %q", key, v.AsString(), want)
+		}
+	}
+}
+
+// 比本包更新的schema不能让InitOtlpProvider panic，错误交给otel的ErrorHandler
+func TestInitOtlpProviderNewerSchema(t *testing.T) {
+	col := newTestCollector(t)
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		errs = append(errs, err)
+	}))
+	prevTP, prevMP, prevProp := otel.GetTracerProvider(), otel.GetMeterProvider(), otel.GetTextMapPropagator()
+	t.Cleanup(func() {
+		// 默认的ErrorHandler同样是log.Print
+		otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) { log.Print(err) }))
+		otel.SetTracerProvider(prevTP)
+		otel.SetMeterProvider(prevMP)
+		otel.SetTextMapPropagator(prevProp)
+	})
+
+	res := resource.NewWithAttributes(schemaURLPrefix+"1.27.0", semconv.ServiceName("newer"))
+	InitOtlpProvider(context.Background(), res, WithEndpoint(col.Endpoint()))
+	_, span := otel.Tracer("schema-test").Start(context.Background(), "op")
+	span.End()
+	if err := Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(errs) == 0 {
+		t.Error("unknown schema was not reported")
+	}
+	spans := col.Spans()
+	if len(spans) != 1 || spans[0].SchemaUrl != schemaURLPrefix+"1.27.0" {
+		t.Fatalf("collector got %v, want the span with the original resource", spans)
+	}
+}
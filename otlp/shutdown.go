@@ -0,0 +1,36 @@
+package otlp
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	shutdownMu    sync.Mutex
+	shutdownFuncs []func(context.Context) error
+)
+
+// onShutdown 登记InitOtlpProvider创建的组件，按登记顺序关闭
+func onShutdown(f func(context.Context) error) {
+	shutdownMu.Lock()
+	defer shutdownMu.Unlock()
+	shutdownFuncs = append(shutdownFuncs, f)
+}
+
+// Shutdown 依次关闭TracerProvider(导出队列里剩余的Span)、MeterProvider(最后一次采集并推送)、Prometheus端口
+// 服务应在停止接收请求、在途请求处理完之后调用，在途请求产生的Span才不会丢
+func Shutdown(ctx context.Context) error {
+	shutdownMu.Lock()
+	funcs := shutdownFuncs
+	shutdownFuncs = nil
+	shutdownMu.Unlock()
+
+	var errs []error
+	for _, f := range funcs {
+		if err := f(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
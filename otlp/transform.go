@@ -0,0 +1,255 @@
+package otlp
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+)
+
+// otlpmetrichttp的转换代码在internal里拿不到，这里自己实现一份SDK数据到OTLP proto的转换
+
+func resourceMetricsToPB(rm *metricdata.ResourceMetrics) *metricpb.ResourceMetrics {
+	out := &metricpb.ResourceMetrics{
+		Resource:  resourceToPB(rm.Resource),
+		SchemaUrl: rm.Resource.SchemaURL(),
+	}
+	for _, sm := range rm.ScopeMetrics {
+		psm := &metricpb.ScopeMetrics{
+			Scope:     scopeToPB(sm.Scope),
+			SchemaUrl: sm.Scope.SchemaURL,
+		}
+		for _, m := range sm.Metrics {
+			if pm := metricToPB(m); pm != nil {
+				psm.Metrics = append(psm.Metrics, pm)
+			}
+		}
+		out.ScopeMetrics = append(out.ScopeMetrics, psm)
+	}
+	return out
+}
+
+func resourceToPB(res *resource.Resource) *resourcepb.Resource {
+	if res == nil {
+		return nil
+	}
+	return &resourcepb.Resource{Attributes: attrsToPB(res.Attributes())}
+}
+
+func scopeToPB(s instrumentation.Scope) *commonpb.InstrumentationScope {
+	return &commonpb.InstrumentationScope{Name: s.Name, Version: s.Version}
+}
+
+func metricToPB(m metricdata.Metrics) *metricpb.Metric {
+	out := &metricpb.Metric{Name: m.Name, Description: m.Description, Unit: m.Unit}
+	switch a := m.Data.(type) {
+	case metricdata.Gauge[int64]:
+		out.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: numberPointsToPB(a.DataPoints)}}
+	case metricdata.Gauge[float64]:
+		out.Data = &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{DataPoints: numberPointsToPB(a.DataPoints)}}
+	case metricdata.Sum[int64]:
+		out.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             numberPointsToPB(a.DataPoints),
+			AggregationTemporality: temporalityToPB(a.Temporality),
+			IsMonotonic:            a.IsMonotonic,
+		}}
+	case metricdata.Sum[float64]:
+		out.Data = &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+			DataPoints:             numberPointsToPB(a.DataPoints),
+			AggregationTemporality: temporalityToPB(a.Temporality),
+			IsMonotonic:            a.IsMonotonic,
+		}}
+	case metricdata.Histogram[int64]:
+		out.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+			DataPoints:             histogramPointsToPB(a.DataPoints),
+			AggregationTemporality: temporalityToPB(a.Temporality),
+		}}
+	case metricdata.Histogram[float64]:
+		out.Data = &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+			DataPoints:             histogramPointsToPB(a.DataPoints),
+			AggregationTemporality: temporalityToPB(a.Temporality),
+		}}
+	case metricdata.ExponentialHistogram[int64]:
+		out.Data = &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{
+			DataPoints:             expHistogramPointsToPB(a.DataPoints),
+			AggregationTemporality: temporalityToPB(a.Temporality),
+		}}
+	case metricdata.ExponentialHistogram[float64]:
+		out.Data = &metricpb.Metric_ExponentialHistogram{ExponentialHistogram: &metricpb.ExponentialHistogram{
+			DataPoints:             expHistogramPointsToPB(a.DataPoints),
+			AggregationTemporality: temporalityToPB(a.Temporality),
+		}}
+	default:
+		return nil
+	}
+	return out
+}
+
+func numberPointsToPB[N int64 | float64](dps []metricdata.DataPoint[N]) []*metricpb.NumberDataPoint {
+	out := make([]*metricpb.NumberDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		p := &metricpb.NumberDataPoint{
+			Attributes:        attrsToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Exemplars:         exemplarsToPB(dp.Exemplars),
+		}
+		switch v := any(dp.Value).(type) {
+		case int64:
+			p.Value = &metricpb.NumberDataPoint_AsInt{AsInt: v}
+		case float64:
+			p.Value = &metricpb.NumberDataPoint_AsDouble{AsDouble: v}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func histogramPointsToPB[N int64 | float64](dps []metricdata.HistogramDataPoint[N]) []*metricpb.HistogramDataPoint {
+	out := make([]*metricpb.HistogramDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		sum := float64(dp.Sum)
+		p := &metricpb.HistogramDataPoint{
+			Attributes:        attrsToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			BucketCounts:      dp.BucketCounts,
+			ExplicitBounds:    dp.Bounds,
+			Exemplars:         exemplarsToPB(dp.Exemplars),
+		}
+		if v, ok := dp.Min.Value(); ok {
+			min := float64(v)
+			p.Min = &min
+		}
+		if v, ok := dp.Max.Value(); ok {
+			max := float64(v)
+			p.Max = &max
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func expHistogramPointsToPB[N int64 | float64](dps []metricdata.ExponentialHistogramDataPoint[N]) []*metricpb.ExponentialHistogramDataPoint {
+	out := make([]*metricpb.ExponentialHistogramDataPoint, 0, len(dps))
+	for _, dp := range dps {
+		sum := float64(dp.Sum)
+		p := &metricpb.ExponentialHistogramDataPoint{
+			Attributes:        attrsToPB(dp.Attributes.ToSlice()),
+			StartTimeUnixNano: unixNano(dp.StartTime),
+			TimeUnixNano:      unixNano(dp.Time),
+			Count:             dp.Count,
+			Sum:               &sum,
+			Scale:             dp.Scale,
+			ZeroCount:         dp.ZeroCount,
+			ZeroThreshold:     dp.ZeroThreshold,
+			Positive: &metricpb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       dp.PositiveBucket.Offset,
+				BucketCounts: dp.PositiveBucket.Counts,
+			},
+			Negative: &metricpb.ExponentialHistogramDataPoint_Buckets{
+				Offset:       dp.NegativeBucket.Offset,
+				BucketCounts: dp.NegativeBucket.Counts,
+			},
+			Exemplars: exemplarsToPB(dp.Exemplars),
+		}
+		if v, ok := dp.Min.Value(); ok {
+			min := float64(v)
+			p.Min = &min
+		}
+		if v, ok := dp.Max.Value(); ok {
+			max := float64(v)
+			p.Max = &max
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func exemplarsToPB[N int64 | float64](exs []metricdata.Exemplar[N]) []*metricpb.Exemplar {
+	if len(exs) == 0 {
+		return nil
+	}
+	out := make([]*metricpb.Exemplar, 0, len(exs))
+	for _, e := range exs {
+		p := &metricpb.Exemplar{
+			FilteredAttributes: attrsToPB(e.FilteredAttributes),
+			TimeUnixNano:       unixNano(e.Time),
+			SpanId:             e.SpanID,
+			TraceId:            e.TraceID,
+		}
+		switch v := any(e.Value).(type) {
+		case int64:
+			p.Value = &metricpb.Exemplar_AsInt{AsInt: v}
+		case float64:
+			p.Value = &metricpb.Exemplar_AsDouble{AsDouble: v}
+		}
+		out = append(out, p)
+	}
+	return out
+}
+
+func temporalityToPB(t metricdata.Temporality) metricpb.AggregationTemporality {
+	switch t {
+	case metricdata.DeltaTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case metricdata.CumulativeTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	}
+	return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+}
+
+func attrsToPB(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, &commonpb.KeyValue{Key: string(kv.Key), Value: valueToPB(kv.Value)})
+	}
+	return out
+}
+
+func valueToPB(v attribute.Value) *commonpb.AnyValue {
+	switch v.Type() {
+	case attribute.BOOL:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}}
+	case attribute.INT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}}
+	case attribute.FLOAT64:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}}
+	case attribute.STRING:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.AsString()}}
+	case attribute.BOOLSLICE:
+		return arrayToPB(v.AsBoolSlice(), attribute.BoolValue)
+	case attribute.INT64SLICE:
+		return arrayToPB(v.AsInt64Slice(), attribute.Int64Value)
+	case attribute.FLOAT64SLICE:
+		return arrayToPB(v.AsFloat64Slice(), attribute.Float64Value)
+	case attribute.STRINGSLICE:
+		return arrayToPB(v.AsStringSlice(), attribute.StringValue)
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v.Emit()}}
+}
+
+func arrayToPB[T any](vs []T, conv func(T) attribute.Value) *commonpb.AnyValue {
+	arr := &commonpb.ArrayValue{}
+	for _, v := range vs {
+		arr.Values = append(arr.Values, valueToPB(conv(v)))
+	}
+	return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{ArrayValue: arr}}
+}
+
+func unixNano(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
@@ -0,0 +1,91 @@
+package otlp
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+const (
+	OtelHTTPScope = "go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	OtelGRPCScope = "go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+)
+
+// ViewConfig 一条视图配置：按Instrument名(支持*通配)和Scope匹配，然后重命名/改聚合/去属性
+type ViewConfig struct {
+	Instrument string
+	Scope      string
+
+	Rename string
+
+	Buckets     []float64 // 显式分桶直方图的边界
+	Exponential bool      // 使用base2指数直方图，优先于Buckets
+	MaxSize     int32     // 指数直方图的最大桶数，0为SDK默认
+	MaxScale    int32     // 指数直方图的最大scale，0为SDK默认
+
+	DropAttributes []string // 去掉的高基数属性
+}
+
+// DurationViews otelhttp/otelgrpc的耗时直方图视图，exponential为false时使用buckets显式分桶
+// 这两个库的耗时单位都是ms
+func DurationViews(buckets []float64, exponential bool) []ViewConfig {
+	return []ViewConfig{
+		{Instrument: "http.server.duration", Scope: OtelHTTPScope, Buckets: buckets, Exponential: exponential},
+		{Instrument: "rpc.server.duration", Scope: OtelGRPCScope, Buckets: buckets, Exponential: exponential},
+		{Instrument: "rpc.client.duration", Scope: OtelGRPCScope, Buckets: buckets, Exponential: exponential},
+	}
+}
+
+func (vc ViewConfig) view() sdkmetric.View {
+	criteria := sdkmetric.Instrument{
+		Name:  vc.Instrument,
+		Scope: instrumentation.Scope{Name: vc.Scope},
+	}
+	mask := sdkmetric.Stream{Name: vc.Rename}
+
+	switch {
+	case vc.Exponential:
+		agg := sdkmetric.AggregationBase2ExponentialHistogram{MaxSize: 160, MaxScale: 20}
+		if vc.MaxSize > 0 {
+			agg.MaxSize = vc.MaxSize
+		}
+		if vc.MaxScale > 0 {
+			agg.MaxScale = vc.MaxScale
+		}
+		mask.Aggregation = agg
+	case vc.Buckets != nil:
+		mask.Aggregation = sdkmetric.AggregationExplicitBucketHistogram{Boundaries: vc.Buckets}
+	}
+
+	if len(vc.DropAttributes) > 0 {
+		drop := make(map[attribute.Key]struct{}, len(vc.DropAttributes))
+		for _, k := range vc.DropAttributes {
+			drop[attribute.Key(k)] = struct{}{}
+		}
+		mask.AttributeFilter = func(kv attribute.KeyValue) bool {
+			_, ok := drop[kv.Key]
+			return !ok
+		}
+	}
+
+	return sdkmetric.NewView(criteria, mask)
+}
+
+func views(cfgs []ViewConfig) []sdkmetric.View {
+	out := make([]sdkmetric.View, 0, len(cfgs))
+	for _, vc := range cfgs {
+		out = append(out, vc.view())
+	}
+	return out
+}
+
+// temporalitySelector 未配置的Instrument类型沿用SDK默认的累积模式
+func temporalitySelector(m map[sdkmetric.InstrumentKind]metricdata.Temporality) sdkmetric.TemporalitySelector {
+	return func(kind sdkmetric.InstrumentKind) metricdata.Temporality {
+		if t, ok := m[kind]; ok {
+			return t
+		}
+		return sdkmetric.DefaultTemporalitySelector(kind)
+	}
+}
@@ -0,0 +1,148 @@
+package otlp
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+// collect 用cfgs创建MeterProvider，执行record后返回名为name的指标
+func collect(t *testing.T, cfgs []ViewConfig, record func(metric.Meter), name string, opts ...sdkmetric.ManualReaderOption) metricdata.Metrics {
+	t.Helper()
+	reader := sdkmetric.NewManualReader(opts...)
+	mp := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader), sdkmetric.WithView(views(cfgs)...))
+	defer mp.Shutdown(context.Background())
+
+	record(mp.Meter("view-test"))
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if m.Name == name {
+				return m
+			}
+		}
+	}
+	t.Fatalf("metric %q not collected: %+v", name, rm.ScopeMetrics)
+	return metricdata.Metrics{}
+}
+
+func recordLatency(m metric.Meter) {
+	h, _ := m.Float64Histogram("latency")
+	h.Record(context.Background(), 12, metric.WithAttributes(attribute.String("route", "/"), attribute.String("user", "u1")))
+}
+
+func TestViewRename(t *testing.T) {
+	m := collect(t, []ViewConfig{{Instrument: "latency", Rename: "request.latency"}}, recordLatency, "request.latency")
+	if _, ok := m.Data.(metricdata.Histogram[float64]); !ok {
+		t.Fatalf("data = %T, want explicit histogram", m.Data)
+	}
+}
+
+func TestViewBuckets(t *testing.T) {
+	m := collect(t, []ViewConfig{{Instrument: "latency", Buckets: []float64{10, 100}}}, recordLatency, "latency")
+	h, ok := m.Data.(metricdata.Histogram[float64])
+	if !ok {
+		t.Fatalf("data = %T, want explicit histogram", m.Data)
+	}
+	dp := h.DataPoints[0]
+	if len(dp.Bounds) != 2 || dp.Bounds[0] != 10 || dp.Bounds[1] != 100 {
+		t.Errorf("bounds = %v, want [10 100]", dp.Bounds)
+	}
+	if dp.BucketCounts[1] != 1 {
+		t.Errorf("bucket counts = %v, want 12 in (10,100]", dp.BucketCounts)
+	}
+}
+
+func TestViewExponential(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		cfg      ViewConfig
+		maxScale int32
+	}{
+		// 默认MaxScale为20，单个值的scale不会被压缩
+		{"defaults", ViewConfig{Instrument: "latency", Exponential: true}, 20},
+		{"max scale", ViewConfig{Instrument: "latency", Exponential: true, MaxScale: 3}, 3},
+		// Exponential优先于Buckets
+		{"over buckets", ViewConfig{Instrument: "latency", Exponential: true, Buckets: []float64{1}, MaxSize: 4}, 20},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			m := collect(t, []ViewConfig{tc.cfg}, recordLatency, "latency")
+			h, ok := m.Data.(metricdata.ExponentialHistogram[float64])
+			if !ok {
+				t.Fatalf("data = %T, want exponential histogram", m.Data)
+			}
+			if got := h.DataPoints[0].Scale; got != tc.maxScale {
+				t.Errorf("scale = %d, want %d", got, tc.maxScale)
+			}
+		})
+	}
+
+	// MaxSize默认160：161个相差很远的值会迫使scale下降
+	spread := func(m metric.Meter) {
+		h, _ := m.Float64Histogram("latency")
+		v := 1.0
+		for i := 0; i < 161; i++ {
+			h.Record(context.Background(), v)
+			v *= 1.1
+		}
+	}
+	small := collect(t, []ViewConfig{{Instrument: "latency", Exponential: true, MaxSize: 4}}, spread, "latency")
+	def := collect(t, []ViewConfig{{Instrument: "latency", Exponential: true}}, spread, "latency")
+	sdp := small.Data.(metricdata.ExponentialHistogram[float64]).DataPoints[0]
+	ddp := def.Data.(metricdata.ExponentialHistogram[float64]).DataPoints[0]
+	if n := len(sdp.PositiveBucket.Counts); n > 4 {
+		t.Errorf("MaxSize 4: %d buckets", n)
+	}
+	if n := len(ddp.PositiveBucket.Counts); n > 160 || n <= 4 {
+		t.Errorf("default MaxSize: %d buckets, want (4,160]", n)
+	}
+}
+
+func TestViewDropAttributes(t *testing.T) {
+	m := collect(t, []ViewConfig{{Instrument: "latency", DropAttributes: []string{"user"}}}, recordLatency, "latency")
+	attrs := m.Data.(metricdata.Histogram[float64]).DataPoints[0].Attributes
+	if _, ok := attrs.Value("user"); ok {
+		t.Error("user attribute was not dropped")
+	}
+	if _, ok := attrs.Value("route"); !ok {
+		t.Error("route attribute was dropped")
+	}
+}
+
+func TestTemporalitySelector(t *testing.T) {
+	sel := temporalitySelector(map[sdkmetric.InstrumentKind]metricdata.Temporality{
+		sdkmetric.InstrumentKindCounter: metricdata.DeltaTemporality,
+	})
+	opt := sdkmetric.WithTemporalitySelector(sel)
+	record := func(m metric.Meter) {
+		c, _ := m.Int64Counter("count")
+		c.Add(context.Background(), 1)
+		h, _ := m.Float64Histogram("latency")
+		h.Record(context.Background(), 1)
+	}
+
+	if got := collect(t, nil, record, "count", opt).Data.(metricdata.Sum[int64]).Temporality; got != metricdata.DeltaTemporality {
+		t.Errorf("counter temporality = %v, want delta", got)
+	}
+	// 未配置的类型回落到累积
+	if got := collect(t, nil, record, "latency", opt).Data.(metricdata.Histogram[float64]).Temporality; got != metricdata.CumulativeTemporality {
+		t.Errorf("histogram temporality = %v, want cumulative", got)
+	}
+	for _, kind := range []sdkmetric.InstrumentKind{
+		sdkmetric.InstrumentKindUpDownCounter,
+		sdkmetric.InstrumentKindObservableCounter,
+		sdkmetric.InstrumentKindObservableGauge,
+	} {
+		if got := sel(kind); got != metricdata.CumulativeTemporality {
+			t.Errorf("%v temporality = %v, want cumulative", kind, got)
+		}
+	}
+}
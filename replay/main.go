@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/tlsutil"
+)
+
+// 把FileExporter录下来的OTLP JSON文件重新发到任意OTLP/HTTP端点
+// go run ./replay -endpoint 127.0.0.1:4318 -rebase traces.jsonl metrics.jsonl
+// TLS端点加上 -tls-ca certs/ca.pem (mTLS再加 -tls-cert/-tls-key)
+func main() {
+	endpoint := flag.String("endpoint", "127.0.0.1:4318", "OTLP/HTTP endpoint")
+	rebase := flag.Bool("rebase", false, "shift timestamps so the earliest one becomes now")
+	tlsCA := flag.String("tls-ca", "", "CA (PEM) for verifying the endpoint, switches to https; see go run ./certgen")
+	tlsCert := flag.String("tls-cert", "", "client certificate (PEM) for mTLS")
+	tlsKey := flag.String("tls-key", "", "client private key (PEM)")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Println("usage: replay [-endpoint host:port] [-rebase] [-tls-ca ca.pem] file...")
+		os.Exit(2)
+	}
+
+	var tlsCfg *tls.Config
+	if *tlsCA != "" {
+		var err error
+		tlsCfg, err = tlsutil.ClientConfig(*tlsCA, *tlsCert, *tlsKey)
+		if err != nil {
+			fmt.Printf("加载证书失败: %s\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if err := otlp.ReplayTLS(context.Background(), *endpoint, tlsCfg, *rebase, flag.Args()...); err != nil {
+		fmt.Printf("重放失败: %s\n", err)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,66 @@
+package requestid
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func fromIncoming(ctx context.Context) context.Context {
+	var incoming string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if v := md.Get(MetadataKey); len(v) > 0 {
+			incoming = v[0]
+		}
+	}
+	ctx, id := ensure(ctx, incoming)
+	record(ctx, id)
+	// 通过响应的header metadata回给调用方
+	_ = grpc.SetHeader(ctx, metadata.Pairs(MetadataKey, id))
+	return ctx
+}
+
+// UnaryServerInterceptor otelgrpc的StatsHandler先创建Server Span，请求ID记在它上面
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		return handler(fromIncoming(ctx), req)
+	}
+}
+
+func StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		return handler(srv, &serverStream{ServerStream: ss, ctx: fromIncoming(ss.Context())})
+	}
+}
+
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+// toOutgoing metadata里已经有请求ID时不覆盖
+func toOutgoing(ctx context.Context) context.Context {
+	if md, ok := metadata.FromOutgoingContext(ctx); ok && len(md.Get(MetadataKey)) > 0 {
+		return ctx
+	}
+	ctx, id := ensure(ctx, "")
+	return metadata.AppendToOutgoingContext(ctx, MetadataKey, id)
+}
+
+// UnaryClientInterceptor 把ctx里的请求ID写进metadata，ctx里没有时生成一个
+func UnaryClientInterceptor() grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		return invoker(toOutgoing(ctx), method, req, reply, cc, opts...)
+	}
+}
+
+func StreamClientInterceptor() grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		return streamer(toOutgoing(ctx), desc, cc, method, opts...)
+	}
+}
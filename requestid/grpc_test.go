@@ -0,0 +1,162 @@
+package requestid
+
+import (
+	"context"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcServer 带请求ID拦截器的健康检查服务，Check是unary，Watch是stream
+// seen返回最近一次handler从ctx里拿到的ID
+func grpcServer(t *testing.T, clientOpts ...grpc.DialOption) (conn *grpc.ClientConn, rec *tracetest.SpanRecorder, seen func() string) {
+	t.Helper()
+	rec, tp := newRecorder(t)
+	var (
+		mu   sync.Mutex
+		last string
+	)
+	save := func(ctx context.Context) {
+		id, _ := FromContext(ctx)
+		mu.Lock()
+		defer mu.Unlock()
+		last = id
+	}
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(
+		grpc.StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(tp))),
+		grpc.ChainUnaryInterceptor(UnaryServerInterceptor(),
+			func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+				save(ctx)
+				return handler(ctx, req)
+			}),
+		grpc.ChainStreamInterceptor(StreamServerInterceptor(),
+			func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+				save(ss.Context())
+				return handler(srv, ss)
+			}),
+	)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet", append([]grpc.DialOption{
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}, clientOpts...)...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn, rec, func() string {
+		mu.Lock()
+		defer mu.Unlock()
+		return last
+	}
+}
+
+// call 调用一次Check或Watch，返回响应header metadata里的ID
+func call(t *testing.T, ctx context.Context, conn *grpc.ClientConn, stream bool) string {
+	t.Helper()
+	client := healthpb.NewHealthClient(conn)
+	var md metadata.MD
+	if !stream {
+		if _, err := client.Check(ctx, &healthpb.HealthCheckRequest{}, grpc.Header(&md)); err != nil {
+			t.Fatal(err)
+		}
+	} else {
+		ctx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		w, err := client.Watch(ctx, &healthpb.HealthCheckRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Recv(); err != nil {
+			t.Fatal(err)
+		}
+		if md, err = w.Header(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if v := md.Get(MetadataKey); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func TestServerInterceptors(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		name := "unary"
+		if stream {
+			name = "stream"
+		}
+		t.Run(name, func(t *testing.T) {
+			for _, tc := range []struct {
+				name     string
+				incoming string
+				keep     bool
+			}{
+				{name: "missing"},
+				{name: "valid", incoming: "order-42/retry", keep: true},
+				{name: "invalid", incoming: "two words"},
+				{name: "oversized", incoming: strings.Repeat("a", maxLen+1)},
+			} {
+				t.Run(tc.name, func(t *testing.T) {
+					conn, rec, seen := grpcServer(t)
+					ctx := context.Background()
+					if tc.incoming != "" {
+						ctx = metadata.AppendToOutgoingContext(ctx, MetadataKey, tc.incoming)
+					}
+					id := call(t, ctx, conn, stream)
+
+					server := endedSpan(t, rec, trace.SpanKindServer)
+					switch {
+					case tc.keep && id != tc.incoming:
+						t.Errorf("response %s = %q, want the inbound %q", MetadataKey, id, tc.incoming)
+					case !tc.keep && id != server.SpanContext().TraceID().String():
+						t.Errorf("response %s = %q, want the trace ID %s", MetadataKey, id, server.SpanContext().TraceID())
+					}
+					if got := seen(); got != id {
+						t.Errorf("FromContext in the handler = %q, want %q", got, id)
+					}
+					if got := spanRequestID(server); got != id {
+						t.Errorf("server span %s = %q, want %q", Key, got, id)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestClientInterceptors(t *testing.T) {
+	conn, _, seen := grpcServer(t,
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor()),
+		grpc.WithStreamInterceptor(StreamClientInterceptor()),
+	)
+	for _, stream := range []bool{false, true} {
+		// ctx里的ID写进metadata
+		if id := call(t, NewContext(context.Background(), "from-ctx"), conn, stream); id != "from-ctx" || seen() != id {
+			t.Errorf("stream=%v: server got %q, response %q, want from-ctx", stream, seen(), id)
+		}
+		// metadata里已有的不覆盖
+		ctx := metadata.AppendToOutgoingContext(NewContext(context.Background(), "from-ctx"), MetadataKey, "from-md")
+		if id := call(t, ctx, conn, stream); id != "from-md" {
+			t.Errorf("stream=%v: response %q, want from-md", stream, id)
+		}
+		// 都没有时生成
+		if id := call(t, context.Background(), conn, stream); len(id) != 32 || seen() != id {
+			t.Errorf("stream=%v: server got %q, response %q, want a generated ID", stream, seen(), id)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package requestid
+
+import (
+	"net/http"
+)
+
+// Handler 保证每个请求都有请求ID，写进响应头并记到当前Span上，下游调用从ctx里取
+// 放在otelhttp.NewHandler里面；生成的ID也写回请求头，后面的bridge.Table能把它提升为Baggage
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		incoming := r.Header.Get(Header)
+		ctx, id := ensure(r.Context(), incoming)
+		if id != incoming {
+			r = r.Clone(ctx)
+			r.Header.Set(Header, id)
+		} else {
+			r = r.WithContext(ctx)
+		}
+		w.Header().Set(Header, id)
+		record(ctx, id)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transport 请求头里没有请求ID时从ctx里取，ctx里也没有就生成一个
+// 放在otelhttp.NewTransport里面时ID记到Client Span上
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		ctx := req.Context()
+		id := req.Header.Get(Header)
+		if id == "" {
+			ctx, id = ensure(ctx, "")
+			req = req.Clone(ctx)
+			req.Header.Set(Header, id)
+		}
+		record(ctx, id)
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
@@ -0,0 +1,156 @@
+package requestid
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func newRecorder(t *testing.T) (*tracetest.SpanRecorder, trace.TracerProvider) {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return rec, tp
+}
+
+// endedSpan 等到kind类型的Span结束，服务端的Span可能在客户端返回之后才结束
+func endedSpan(t *testing.T, rec *tracetest.SpanRecorder, kind trace.SpanKind) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, s := range rec.Ended() {
+			if s.SpanKind() == kind {
+				return s
+			}
+		}
+	}
+	t.Fatalf("no %s span ended", kind)
+	return nil
+}
+
+func spanRequestID(s sdktrace.ReadOnlySpan) string {
+	for _, kv := range s.Attributes() {
+		if kv.Key == Key {
+			return kv.Value.AsString()
+		}
+	}
+	return ""
+}
+
+// 经过otelhttp.NewHandler(Handler)的服务端，handler里用Transport调下游
+func TestHandler(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		incoming string
+		keep     bool
+	}{
+		{name: "missing"},
+		{name: "valid", incoming: "order-42/retry", keep: true},
+		{name: "invalid", incoming: "two words"},
+		{name: "oversized", incoming: strings.Repeat("a", maxLen+1)},
+		{name: "max length", incoming: strings.Repeat("a", maxLen), keep: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec, tp := newRecorder(t)
+			var downstream string
+			down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				downstream = r.Header.Get(Header)
+			}))
+			defer down.Close()
+			client := &http.Client{Transport: otelhttp.NewTransport(Transport(nil), otelhttp.WithTracerProvider(tp))}
+
+			var fromCtx, fromHeader string
+			srv := httptest.NewServer(otelhttp.NewHandler(Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				fromCtx, _ = FromContext(r.Context())
+				fromHeader = r.Header.Get(Header)
+				req, _ := http.NewRequestWithContext(r.Context(), http.MethodGet, down.URL, nil)
+				resp, err := client.Do(req)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				resp.Body.Close()
+			})), "server", otelhttp.WithTracerProvider(tp)))
+			defer srv.Close()
+
+			req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+			if tc.incoming != "" {
+				req.Header.Set(Header, tc.incoming)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			id := resp.Header.Get(Header)
+			server := endedSpan(t, rec, trace.SpanKindServer)
+			switch {
+			case tc.keep && id != tc.incoming:
+				t.Errorf("response %s = %q, want the inbound %q", Header, id, tc.incoming)
+			case !tc.keep && id != server.SpanContext().TraceID().String():
+				// 生成的ID就是TraceID
+				t.Errorf("response %s = %q, want the trace ID %s", Header, id, server.SpanContext().TraceID())
+			}
+			for what, got := range map[string]string{
+				"FromContext":            fromCtx,
+				"request header":         fromHeader,
+				"server span":            spanRequestID(server),
+				"downstream header":      downstream,
+				"downstream client span": spanRequestID(endedSpan(t, rec, trace.SpanKindClient)),
+			} {
+				if got != id {
+					t.Errorf("%s = %q, want %q", what, got, id)
+				}
+			}
+		})
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var got string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Get(Header)
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: Transport(nil)}
+
+	get := func(ctx context.Context, header string) {
+		t.Helper()
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+		if header != "" {
+			req.Header.Set(Header, header)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if req.Header.Get(Header) != header {
+			t.Error("caller's request was modified")
+		}
+	}
+
+	// 请求头优先于ctx
+	get(NewContext(context.Background(), "from-ctx"), "from-header")
+	if got != "from-header" {
+		t.Errorf("got %q, want the request header", got)
+	}
+	get(NewContext(context.Background(), "from-ctx"), "")
+	if got != "from-ctx" {
+		t.Errorf("got %q, want the ID from ctx", got)
+	}
+	// 没有Span时随机生成
+	get(context.Background(), "")
+	if len(got) != 32 || !valid(got) {
+		t.Errorf("generated %q", got)
+	}
+}
@@ -0,0 +1,75 @@
+package requestid
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Header 用户能看到的请求ID，客户支持凭它找到对应的Trace
+const Header = "X-Request-ID"
+
+// MetadataKey gRPC里的请求ID，服务端也通过响应的header metadata返回
+const MetadataKey = "x-request-id"
+
+// Key 记在Server Span和Client Span上
+const Key = attribute.Key("request.id")
+
+// maxLen 调用方传来的ID过长或含有不可见字符时重新生成，避免被写进日志和Span里造成注入
+const maxLen = 128
+
+type idKey struct{}
+
+func NewContext(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, idKey{}, id)
+}
+
+func FromContext(ctx context.Context) (string, bool) {
+	id, ok := ctx.Value(idKey{}).(string)
+	return id, ok && id != ""
+}
+
+// New ctx里有Span时使用TraceID，拿着请求ID就能直接查Trace，重试的每次尝试也得到相同的ID
+// 没有Span时随机生成
+func New(ctx context.Context) string {
+	if sc := trace.SpanContextFromContext(ctx); sc.TraceID().IsValid() {
+		return sc.TraceID().String()
+	}
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ensure 依次使用ctx里已有的ID、调用方传来的合法ID，都没有时生成一个
+func ensure(ctx context.Context, incoming string) (context.Context, string) {
+	if id, ok := FromContext(ctx); ok {
+		return ctx, id
+	}
+	id := incoming
+	if !valid(id) {
+		id = New(ctx)
+	}
+	return NewContext(ctx, id), id
+}
+
+func valid(id string) bool {
+	if id == "" || len(id) > maxLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// record 手写埋点的Twin在中间件里还没有Span，由handler自己从FromContext取出记录
+func record(ctx context.Context, id string) {
+	if span := trace.SpanFromContext(ctx); span.IsRecording() {
+		span.SetAttributes(Key.String(id))
+	}
+}
@@ -0,0 +1,92 @@
+package retry
+
+import (
+	"context"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryClientInterceptor 与Transport相同，逻辑操作Span下每次尝试由otelgrpc各自产生Client Span
+// 每次尝试的Span上的retry.attempt由AnnotateStatsHandler记录
+func UnaryClientInterceptor(policy Policy) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, span := tracer().Start(ctx, "retry "+method, trace.WithAttributes(semconv.RPCSystemGRPC))
+		defer span.End()
+
+		var (
+			err     error
+			outcome string
+			attempt int
+		)
+		for attempt = 1; ; attempt++ {
+			err = invoker(withAttempt(ctx, attempt), method, req, reply, cc, opts...)
+			code := status.Code(err)
+			if err == nil || !slices.Contains(policy.RetryableCodes, code) {
+				// 与Transport相同，只有成功才补充预算
+				if err == nil {
+					policy.Budget.onSuccess()
+					outcome = OutcomeSuccess
+				} else {
+					outcome = OutcomeNonRetryable
+				}
+				break
+			}
+			if ctx.Err() != nil {
+				outcome = OutcomeCanceled
+				break
+			}
+			policy.Budget.onFailure()
+			if attempt >= policy.MaxAttempts {
+				outcome = OutcomeExhausted
+				break
+			}
+			if !policy.Budget.allow() {
+				outcome = OutcomeBudgetExhausted
+				break
+			}
+
+			backoff := policy.backoff(attempt)
+			span.AddEvent("retry", trace.WithAttributes(
+				AttemptKey.Int(attempt),
+				attribute.String("retry.reason", code.String()),
+				attribute.Int64("retry.backoff_ms", backoff.Milliseconds()),
+			))
+			if werr := wait(ctx, backoff); werr != nil {
+				outcome = OutcomeCanceled
+				break
+			}
+		}
+
+		span.SetAttributes(AttemptsKey.Int(attempt), OutcomeKey.String(outcome))
+		span.SetAttributes(semconv.RPCGRPCStatusCodeKey.Int(int(status.Code(err))))
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		return err
+	}
+}
+
+// AnnotateStatsHandler 包在otelgrpc.NewClientHandler()外面，inner创建Span之后把重试次数记上去
+func AnnotateStatsHandler(inner stats.Handler) stats.Handler {
+	return &annotateHandler{Handler: inner}
+}
+
+type annotateHandler struct {
+	stats.Handler
+}
+
+func (h *annotateHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	ctx = h.Handler.TagRPC(ctx, info)
+	if attempt := Attempt(ctx); attempt > 0 {
+		trace.SpanFromContext(ctx).SetAttributes(AttemptKey.Int(attempt))
+	}
+	return ctx
+}
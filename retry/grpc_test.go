@@ -0,0 +1,108 @@
+package retry
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/chaos"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestUnaryClientInterceptorExhausted(t *testing.T) {
+	rec := newRecorder(t)
+
+	injector := chaos.NewInjector()
+	injector.Set(chaos.Any, chaos.Fault{ErrorRate: 1}) // 默认Unavailable
+	var hits int
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.ChainUnaryInterceptor(
+		func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			hits++
+			return handler(ctx, req)
+		},
+		injector.UnaryServerInterceptor(),
+	))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(AnnotateStatsHandler(otelgrpc.NewClientHandler())),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(testPolicy())),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	_, err = healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{})
+	if status.Code(err) != codes.Unavailable {
+		t.Fatalf("got %v, want Unavailable", err)
+	}
+	if hits != 3 {
+		t.Errorf("server got %d calls, want 3", hits)
+	}
+
+	attempts := spansOf(rec, trace.SpanKindClient, "")
+	if len(attempts) != 3 {
+		t.Fatalf("got %d attempt spans, want 3", len(attempts))
+	}
+	for i, s := range attempts {
+		if v, _ := attr(s, AttemptKey); v.AsInt64() != int64(i+1) {
+			t.Errorf("attempt span %d: %s=%d", i, AttemptKey, v.AsInt64())
+		}
+	}
+	logical := spansOf(rec, trace.SpanKindInternal, "retry ")[0]
+	if v, _ := attr(logical, OutcomeKey); v.AsString() != OutcomeExhausted {
+		t.Errorf("%s=%q, want %q", OutcomeKey, v.AsString(), OutcomeExhausted)
+	}
+}
+
+// 不可重试的状态码(NotFound)不补充预算，成功才补充
+func TestUnaryClientInterceptorBudgetRefill(t *testing.T) {
+	newRecorder(t)
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	policy := testPolicy()
+	policy.Budget = NewBudget(10, 1)
+	policy.Budget.tokens = 5
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(UnaryClientInterceptor(policy)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	// health.Server对未注册的服务返回NotFound
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{Service: "missing"}); status.Code(err) != codes.NotFound {
+		t.Fatalf("got %v, want NotFound", err)
+	}
+	if got := policy.Budget.tokens; got != 5 {
+		t.Errorf("tokens after NotFound = %v, want 5", got)
+	}
+	if _, err := client.Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := policy.Budget.tokens; got != 6 {
+		t.Errorf("tokens after success = %v, want 6", got)
+	}
+}
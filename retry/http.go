@@ -0,0 +1,182 @@
+package retry
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Transport 按Policy重试，外面包一个逻辑操作Span，每次尝试由base(otelhttp或手写的Transport)各自产生Client Span
+// base下面再套一层AnnotateTransport，每次尝试的Span上才会有retry.attempt与http.request.resend_count
+type Transport struct {
+	base   http.RoundTripper
+	policy Policy
+}
+
+func NewTransport(base http.RoundTripper, policy Policy) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{base: base, policy: policy}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer().Start(req.Context(), "retry "+req.Method, trace.WithAttributes(semconv.HTTPRequestMethodKey.String(req.Method)))
+	defer span.End()
+
+	// 不可重放的body或非幂等方法只发一次
+	replayable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	maxAttempts := t.policy.MaxAttempts
+	if !replayable || (!t.policy.RetryNonIdempotent && !idempotent(req.Method)) || maxAttempts < 1 {
+		maxAttempts = 1
+	}
+
+	var (
+		resp    *http.Response
+		err     error
+		outcome string
+		attempt int
+	)
+	for attempt = 1; ; attempt++ {
+		attemptReq := req.Clone(withAttempt(ctx, attempt))
+		if attempt > 1 && req.GetBody != nil {
+			if attemptReq.Body, err = req.GetBody(); err != nil {
+				outcome = OutcomeNonRetryable
+				break
+			}
+		}
+		resp, err = t.base.RoundTrip(attemptReq)
+
+		if err == nil && !slices.Contains(t.policy.RetryableStatus, resp.StatusCode) {
+			// 只有真正成功才补充预算，不可重试的失败既不算成功也不消耗预算
+			if resp.StatusCode >= 400 {
+				outcome = OutcomeNonRetryable
+			} else {
+				t.policy.Budget.onSuccess()
+				outcome = OutcomeSuccess
+			}
+			break
+		}
+		if err != nil && permanent(err) {
+			outcome = OutcomeNonRetryable
+			break
+		}
+		if ctx.Err() != nil {
+			outcome = OutcomeCanceled
+			break
+		}
+		t.policy.Budget.onFailure()
+		if attempt >= maxAttempts {
+			outcome = OutcomeExhausted
+			break
+		}
+		if !t.policy.Budget.allow() {
+			outcome = OutcomeBudgetExhausted
+			break
+		}
+
+		backoff := t.policy.backoff(attempt)
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+		}
+		span.AddEvent("retry", trace.WithAttributes(
+			AttemptKey.Int(attempt),
+			attribute.String("retry.reason", reason),
+			attribute.Int64("retry.backoff_ms", backoff.Milliseconds()),
+		))
+		// 丢弃这次的响应，连接才能复用
+		if resp != nil {
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := wait(ctx, backoff); err != nil {
+			outcome = OutcomeCanceled
+			resp = nil
+			break
+		}
+	}
+
+	span.SetAttributes(AttemptsKey.Int(attempt), OutcomeKey.String(outcome))
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	case resp == nil:
+		err = ctx.Err()
+		span.SetStatus(codes.Error, outcome)
+	default:
+		span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.SetStatus(codes.Error, resp.Status)
+		}
+	}
+	return resp, err
+}
+
+// permanent 证书校验失败、域名不存在这类错误，重试也不会成功
+func permanent(err error) bool {
+	var (
+		verifyErr   *tls.CertificateVerificationError
+		unknownCA   x509.UnknownAuthorityError
+		hostnameErr x509.HostnameError
+		invalidErr  x509.CertificateInvalidError
+		dnsErr      *net.DNSError
+	)
+	switch {
+	case errors.As(err, &verifyErr), errors.As(err, &unknownCA), errors.As(err, &hostnameErr), errors.As(err, &invalidErr):
+		return true
+	case errors.As(err, &dnsErr):
+		return dnsErr.IsNotFound
+	}
+	return false
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// AnnotateTransport 放在埋点Transport下面，把重试次数记到这次尝试的Client Span上
+// 重定向也算重发，与重试次数相加，见semconv的http.request.resend_count
+func AnnotateTransport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		if attempt := Attempt(req.Context()); attempt > 0 {
+			span := trace.SpanFromContext(req.Context())
+			span.SetAttributes(AttemptKey.Int(attempt))
+			if resends := attempt - 1 + redirects(req); resends > 0 {
+				span.SetAttributes(semconv.HTTPRequestResendCount(resends))
+			}
+		}
+		return base.RoundTrip(req)
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func redirects(req *http.Request) int {
+	n := 0
+	for r := req.Response; r != nil && r.Request != nil; r = r.Request.Response {
+		n++
+	}
+	return n
+}
@@ -0,0 +1,254 @@
+package retry
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/chaos"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// newRecorder 重试的逻辑Span用全局Provider，测试期间换成内存里的SpanRecorder
+func newRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+		_ = tp.Shutdown(context.Background())
+	})
+	return rec
+}
+
+// testPolicy 不等待、不抖动，结果只取决于故障规则
+func testPolicy() Policy {
+	return Policy{
+		MaxAttempts:     3,
+		InitialBackoff:  time.Millisecond,
+		Multiplier:      1,
+		RetryableStatus: []int{http.StatusServiceUnavailable},
+		RetryableCodes:  DefaultPolicy().RetryableCodes,
+	}
+}
+
+// spansOf 按结束顺序返回指定类型、名字前缀的Span
+func spansOf(rec *tracetest.SpanRecorder, kind trace.SpanKind, prefix string) []sdktrace.ReadOnlySpan {
+	var out []sdktrace.ReadOnlySpan
+	for _, s := range rec.Ended() {
+		if s.SpanKind() == kind && strings.HasPrefix(s.Name(), prefix) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+func attr(s sdktrace.ReadOnlySpan, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+// unavailableServer 所有请求都由chaos返回503，返回收到的请求数
+func unavailableServer(t *testing.T) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+	injector := chaos.NewInjector()
+	injector.Set(chaos.Any, chaos.Fault{ErrorRate: 1, HTTPStatus: http.StatusServiceUnavailable})
+	var hits atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		injector.Handler("/", http.NotFoundHandler()).ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &hits
+}
+
+func do(t *testing.T, tr http.RoundTripper, method, url string) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, strings.NewReader("body"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := tr.RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("status %d, want 503", resp.StatusCode)
+	}
+}
+
+func TestTransportExhausted(t *testing.T) {
+	rec := newRecorder(t)
+	srv, hits := unavailableServer(t)
+	tr := NewTransport(otelhttp.NewTransport(AnnotateTransport(nil)), testPolicy())
+	do(t, tr, http.MethodGet, srv.URL)
+
+	if hits.Load() != 3 {
+		t.Errorf("server got %d requests, want 3", hits.Load())
+	}
+	attempts := spansOf(rec, trace.SpanKindClient, "")
+	if len(attempts) != 3 {
+		t.Fatalf("got %d attempt spans, want 3", len(attempts))
+	}
+	for i, s := range attempts {
+		if v, _ := attr(s, AttemptKey); v.AsInt64() != int64(i+1) {
+			t.Errorf("attempt span %d: %s=%d", i, AttemptKey, v.AsInt64())
+		}
+		v, ok := attr(s, semconv.HTTPRequestResendCountKey)
+		if i == 0 && ok || i > 0 && v.AsInt64() != int64(i) {
+			t.Errorf("attempt span %d: %s=%v", i, semconv.HTTPRequestResendCountKey, v.Emit())
+		}
+	}
+
+	logical := spansOf(rec, trace.SpanKindInternal, "retry ")
+	if len(logical) != 1 {
+		t.Fatalf("got %d logical spans, want 1", len(logical))
+	}
+	if v, _ := attr(logical[0], OutcomeKey); v.AsString() != OutcomeExhausted {
+		t.Errorf("%s=%q, want %q", OutcomeKey, v.AsString(), OutcomeExhausted)
+	}
+	if v, _ := attr(logical[0], AttemptsKey); v.AsInt64() != 3 {
+		t.Errorf("%s=%d, want 3", AttemptsKey, v.AsInt64())
+	}
+	// 第1、2次失败后各有一个retry事件
+	if n := len(logical[0].Events()); n != 2 {
+		t.Errorf("got %d retry events, want 2", n)
+	}
+	for _, s := range attempts {
+		if s.Parent().SpanID() != logical[0].SpanContext().SpanID() {
+			t.Errorf("attempt span %s is not a child of the logical span", s.Name())
+		}
+	}
+}
+
+func TestTransportBudgetExhausted(t *testing.T) {
+	rec := newRecorder(t)
+	srv, hits := unavailableServer(t)
+	policy := testPolicy()
+	// 一次失败后令牌剩1，不超过一半，不再重试
+	policy.Budget = NewBudget(2, 0.1)
+	do(t, NewTransport(AnnotateTransport(nil), policy), http.MethodGet, srv.URL)
+
+	if hits.Load() != 1 {
+		t.Errorf("server got %d requests, want 1", hits.Load())
+	}
+	logical := spansOf(rec, trace.SpanKindInternal, "retry ")[0]
+	if v, _ := attr(logical, OutcomeKey); v.AsString() != OutcomeBudgetExhausted {
+		t.Errorf("%s=%q, want %q", OutcomeKey, v.AsString(), OutcomeBudgetExhausted)
+	}
+}
+
+func TestTransportPOSTNotRetried(t *testing.T) {
+	rec := newRecorder(t)
+	srv, hits := unavailableServer(t)
+	do(t, NewTransport(AnnotateTransport(nil), testPolicy()), http.MethodPost, srv.URL)
+
+	if hits.Load() != 1 {
+		t.Errorf("server got %d requests, want 1", hits.Load())
+	}
+	logical := spansOf(rec, trace.SpanKindInternal, "retry ")[0]
+	if v, _ := attr(logical, AttemptsKey); v.AsInt64() != 1 {
+		t.Errorf("%s=%d, want 1", AttemptsKey, v.AsInt64())
+	}
+}
+
+// countingTransport 记录尝试次数
+type countingTransport struct {
+	base  http.RoundTripper
+	calls int
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	c.calls++
+	return c.base.RoundTrip(req)
+}
+
+type errTransport struct{ err error }
+
+func (e errTransport) RoundTrip(*http.Request) (*http.Response, error) { return nil, e.err }
+
+func TestTransportPermanentErrors(t *testing.T) {
+	tlsSrv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsSrv.Close()
+
+	for _, tc := range []struct {
+		name    string
+		base    http.RoundTripper
+		url     string
+		outcome string
+		calls   int
+	}{
+		// 不信任httptest的证书
+		{"untrusted certificate", http.DefaultTransport.(*http.Transport).Clone(), tlsSrv.URL, OutcomeNonRetryable, 1},
+		{"nxdomain", errTransport{&net.DNSError{Err: "no such host", Name: "nope.invalid", IsNotFound: true}}, "http://nope.invalid", OutcomeNonRetryable, 1},
+		// DNS超时等临时错误照常重试
+		{"dns timeout", errTransport{&net.DNSError{Err: "i/o timeout", Name: "slow.example", IsTimeout: true}}, "http://slow.example", OutcomeExhausted, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := newRecorder(t)
+			base := &countingTransport{base: tc.base}
+			req, _ := http.NewRequest(http.MethodGet, tc.url, nil)
+			if _, err := NewTransport(base, testPolicy()).RoundTrip(req); err == nil {
+				t.Fatal("want error")
+			}
+			if base.calls != tc.calls {
+				t.Errorf("%d attempts, want %d", base.calls, tc.calls)
+			}
+			logical := spansOf(rec, trace.SpanKindInternal, "retry ")[0]
+			if v, _ := attr(logical, OutcomeKey); v.AsString() != tc.outcome {
+				t.Errorf("%s=%q, want %q", OutcomeKey, v.AsString(), tc.outcome)
+			}
+		})
+	}
+}
+
+// 只有成功的请求补充预算，4xx这类不可重试的失败不补充
+func TestTransportBudgetRefill(t *testing.T) {
+	newRecorder(t)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	policy := testPolicy()
+	policy.Budget = NewBudget(10, 1)
+	policy.Budget.tokens = 5
+	tr := NewTransport(nil, policy)
+	for _, tc := range []struct {
+		path   string
+		tokens float64
+	}{
+		{"/missing", 5},
+		{"/", 6},
+	} {
+		req, _ := http.NewRequest(http.MethodGet, srv.URL+tc.path, nil)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if got := policy.Budget.tokens; got != tc.tokens {
+			t.Errorf("%s: tokens %v, want %v", tc.path, got, tc.tokens)
+		}
+	}
+}
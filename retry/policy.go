@@ -0,0 +1,150 @@
+package retry
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/codes"
+)
+
+const ScopeName = "github.com/dextercai/OpenTelemetry-Golang-Playground/retry"
+
+// 逻辑操作Span与每次尝试上的属性
+const (
+	AttemptKey  = attribute.Key("retry.attempt")  // 第几次尝试，从1开始
+	AttemptsKey = attribute.Key("retry.attempts") // 逻辑操作一共尝试了几次
+	OutcomeKey  = attribute.Key("retry.outcome")
+)
+
+// 逻辑操作的结果
+const (
+	OutcomeSuccess         = "success"
+	OutcomeNonRetryable    = "non_retryable"    // 错误不在可重试范围内
+	OutcomeExhausted       = "exhausted"        // 达到MaxAttempts
+	OutcomeBudgetExhausted = "budget_exhausted" // 重试预算用完
+	OutcomeCanceled        = "canceled"         // 调用方取消或超时
+)
+
+// Policy 两种传输共用的重试策略
+type Policy struct {
+	MaxAttempts    int           // 包括第一次，<=1时不重试
+	InitialBackoff time.Duration // 第一次重试前的等待
+	MaxBackoff     time.Duration
+	Multiplier     float64 // 每次重试等待时间的倍数
+	Jitter         float64 // 0~1，等待时间在±Jitter比例内随机，避免客户端同时重试
+
+	RetryableStatus []int        // HTTP状态码
+	RetryableCodes  []codes.Code // gRPC状态码
+	// 默认只重试幂等的HTTP方法(GET、HEAD、OPTIONS、PUT、DELETE、TRACE)
+	RetryNonIdempotent bool
+
+	Budget *Budget // nil为不限制
+}
+
+// DefaultPolicy 3次尝试，100ms起指数退避，最长1s，±20%抖动
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     3,
+		InitialBackoff:  100 * time.Millisecond,
+		MaxBackoff:      time.Second,
+		Multiplier:      2,
+		Jitter:          0.2,
+		RetryableStatus: []int{http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout},
+		RetryableCodes:  []codes.Code{codes.Unavailable, codes.ResourceExhausted},
+		Budget:          NewBudget(10, 0.1),
+	}
+}
+
+var (
+	rngMu sync.Mutex
+	rng   = rand.New(rand.NewSource(time.Now().UnixNano()))
+)
+
+// backoff 第attempt次尝试失败后、下一次尝试前的等待时间
+func (p Policy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff) * math.Pow(p.Multiplier, float64(attempt-1))
+	if p.Jitter > 0 {
+		rngMu.Lock()
+		d *= 1 - p.Jitter + 2*p.Jitter*rng.Float64()
+		rngMu.Unlock()
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		d = float64(p.MaxBackoff)
+	}
+	return time.Duration(d)
+}
+
+// Budget 与gRPC的retryThrottling相同：令牌初始为满，失败减1，成功加ratio，
+// 令牌不超过一半时不再重试，下游整体故障时避免重试把流量放大数倍
+type Budget struct {
+	mu        sync.Mutex
+	maxTokens float64
+	ratio     float64
+	tokens    float64
+}
+
+func NewBudget(maxTokens, ratio float64) *Budget {
+	return &Budget{maxTokens: maxTokens, ratio: ratio, tokens: maxTokens}
+}
+
+func (b *Budget) allow() bool {
+	if b == nil {
+		return true
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens > b.maxTokens/2
+}
+
+func (b *Budget) onFailure() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Max(0, b.tokens-1)
+}
+
+func (b *Budget) onSuccess() {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.tokens = math.Min(b.maxTokens, b.tokens+b.ratio)
+}
+
+type attemptKey struct{}
+
+// Attempt 当前是逻辑操作的第几次尝试，不在重试中时为0
+func Attempt(ctx context.Context) int {
+	n, _ := ctx.Value(attemptKey{}).(int)
+	return n
+}
+
+func withAttempt(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, attemptKey{}, n)
+}
+
+func tracer() trace.Tracer {
+	return otel.Tracer(ScopeName)
+}
+
+// wait 等待退避时间，期间调用方取消则返回错误
+func wait(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,101 @@
+package tlsutil
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/stats"
+)
+
+// Attributes 连接的TLS版本、加密套件和对端证书
+// server为true时是服务端视角，对端证书记为tls.client.*(mTLS时才有)，否则记为tls.server.*
+func Attributes(state *tls.ConnectionState, server bool) []attribute.KeyValue {
+	if state == nil {
+		return nil
+	}
+	attrs := []attribute.KeyValue{
+		semconv.TLSProtocolNameTLS,
+		semconv.TLSProtocolVersion(strings.TrimPrefix(tls.VersionName(state.Version), "TLS ")),
+		semconv.TLSCipher(tls.CipherSuiteName(state.CipherSuite)),
+		semconv.TLSEstablished(state.HandshakeComplete),
+		semconv.TLSResumed(state.DidResume),
+	}
+	if state.NegotiatedProtocol != "" {
+		attrs = append(attrs, semconv.TLSNextProtocol(state.NegotiatedProtocol))
+	}
+	if state.ServerName != "" {
+		attrs = append(attrs, semconv.TLSClientServerName(state.ServerName))
+	}
+	if len(state.PeerCertificates) > 0 {
+		c := state.PeerCertificates[0]
+		if server {
+			attrs = append(attrs, semconv.TLSClientSubject(c.Subject.String()), semconv.TLSClientIssuer(c.Issuer.String()))
+		} else {
+			attrs = append(attrs, semconv.TLSServerSubject(c.Subject.String()), semconv.TLSServerIssuer(c.Issuer.String()))
+		}
+	}
+	return attrs
+}
+
+// Transport 把服务端的TLS信息记到请求ctx里的Span上，包在otelhttp.NewTransport里面时就是Client Span
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		resp, err := base.RoundTrip(req)
+		if err == nil && resp.TLS != nil {
+			trace.SpanFromContext(req.Context()).SetAttributes(Attributes(resp.TLS, false)...)
+		}
+		return resp, err
+	})
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+// Handler 把客户端的TLS信息记到请求ctx里的Span上，要放在otelhttp.NewHandler里面
+// 手写埋点的服务端在handler里自己调用Attributes(r.TLS, true)
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil {
+			trace.SpanFromContext(r.Context()).SetAttributes(Attributes(r.TLS, true)...)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// StatsHandler 包在otelgrpc的StatsHandler外面，RPC结束前把连接的TLS信息记到inner创建的Span上
+// 客户端和服务端都可以用
+func StatsHandler(inner stats.Handler) stats.Handler {
+	return &statsHandler{Handler: inner}
+}
+
+type statsHandler struct {
+	stats.Handler
+}
+
+func (h *statsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	if end, ok := s.(*stats.End); ok {
+		annotatePeer(ctx, !end.Client)
+	}
+	h.Handler.HandleRPC(ctx, s)
+}
+
+func annotatePeer(ctx context.Context, server bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return
+	}
+	if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+		trace.SpanFromContext(ctx).SetAttributes(Attributes(&info.State, server)...)
+	}
+}
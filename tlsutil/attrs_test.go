@@ -0,0 +1,167 @@
+package tlsutil
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func newRecorder(t *testing.T) (*tracetest.SpanRecorder, trace.TracerProvider) {
+	t.Helper()
+	rec := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	t.Cleanup(func() { _ = tp.Shutdown(context.Background()) })
+	return rec, tp
+}
+
+// endedSpan 等到kind类型的Span结束，服务端的Span可能在客户端返回之后才结束
+func endedSpan(t *testing.T, rec *tracetest.SpanRecorder, kind trace.SpanKind) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		for _, s := range rec.Ended() {
+			if s.SpanKind() == kind {
+				return s
+			}
+		}
+	}
+	t.Fatalf("no %s span ended", kind)
+	return nil
+}
+
+func checkAttrs(t *testing.T, s sdktrace.ReadOnlySpan, want map[string]string) {
+	t.Helper()
+	got := make(map[string]string)
+	for _, kv := range s.Attributes() {
+		got[string(kv.Key)] = kv.Value.Emit()
+	}
+	for k, v := range want {
+		if got[k] != v {
+			t.Errorf("%s span: %s = %q, want %q", s.SpanKind(), k, got[k], v)
+		}
+	}
+}
+
+// 服务端记对端(客户端)证书，客户端记服务端证书
+var (
+	serverSideTLS = map[string]string{
+		"tls.protocol.name":    "tls",
+		"tls.protocol.version": "1.3",
+		"tls.established":      "true",
+		"tls.client.subject":   "CN=test-client",
+		"tls.client.issuer":    "CN=test CA",
+	}
+	clientSideTLS = map[string]string{
+		"tls.protocol.name":    "tls",
+		"tls.protocol.version": "1.3",
+		"tls.established":      "true",
+		"tls.server.subject":   "CN=test-server",
+		"tls.server.issuer":    "CN=test CA",
+	}
+)
+
+func TestHTTPAttributes(t *testing.T) {
+	rec, tp := newRecorder(t)
+	dir := certDir(t)
+	srv := newMTLSServer(t, dir, otelhttp.NewHandler(Handler(http.NotFoundHandler()), "server", otelhttp.WithTracerProvider(tp)))
+
+	base := &http.Transport{TLSClientConfig: clientConfig(t, dir, "client")}
+	defer base.CloseIdleConnections()
+	c := &http.Client{Transport: otelhttp.NewTransport(Transport(base), otelhttp.WithTracerProvider(tp))}
+	resp, err := c.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	checkAttrs(t, endedSpan(t, rec, trace.SpanKindServer), serverSideTLS)
+	checkAttrs(t, endedSpan(t, rec, trace.SpanKindClient), clientSideTLS)
+}
+
+func TestGRPCAttributes(t *testing.T) {
+	rec, tp := newRecorder(t)
+	dir := certDir(t)
+	serverCfg, err := ServerConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := grpc.NewServer(
+		grpc.Creds(credentials.NewTLS(serverCfg)),
+		grpc.StatsHandler(StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(tp)))),
+	)
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient(lis.Addr().String(),
+		grpc.WithTransportCredentials(credentials.NewTLS(clientConfig(t, dir, "client"))),
+		grpc.WithStatsHandler(StatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tp)))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	server := endedSpan(t, rec, trace.SpanKindServer)
+	checkAttrs(t, server, serverSideTLS)
+	checkAttrs(t, server, map[string]string{"tls.next_protocol": "h2"})
+	checkAttrs(t, endedSpan(t, rec, trace.SpanKindClient), clientSideTLS)
+}
+
+// 不是TLS连接时StatsHandler不加属性，其它回调照常交给inner
+func TestStatsHandlerInsecure(t *testing.T) {
+	rec, tp := newRecorder(t)
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer(grpc.StatsHandler(StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(tp)))))
+	healthpb.RegisterHealthServer(s, health.NewServer())
+	go s.Serve(lis)
+	defer s.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithStatsHandler(StatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithTracerProvider(tp)))),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := healthpb.NewHealthClient(conn).Check(context.Background(), &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, kind := range []trace.SpanKind{trace.SpanKindServer, trace.SpanKindClient} {
+		span := endedSpan(t, rec, kind)
+		// Span名和rpc.*属性来自otelgrpc，说明TagRPC等回调没有丢
+		if span.Name() != "grpc.health.v1.Health/Check" {
+			t.Errorf("%s span name %q", kind, span.Name())
+		}
+		for _, kv := range span.Attributes() {
+			if strings.HasPrefix(string(kv.Key), "tls.") {
+				t.Errorf("%s span has %s on an insecure connection", kind, kv.Key)
+			}
+		}
+	}
+}
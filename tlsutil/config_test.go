@@ -0,0 +1,141 @@
+package tlsutil
+
+import (
+	"crypto/tls"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// certDir 与certgen一样在dir下写出ca.pem、server.pem、client.pem，
+// 另有一个不相关的CA签发的other.pem
+func certDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	ca, err := NewCA("test CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "ca.pem"), ca.CertPEM(), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.IssueFiles(dir, "server", "test-server", "localhost", "127.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ca.IssueFiles(dir, "client", "test-client"); err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewCA("other CA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.IssueFiles(dir, "other", "test-client"); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+// newMTLSServer 要求客户端出示由ca.pem签发的证书
+func newMTLSServer(t *testing.T, dir string, h http.Handler) *httptest.Server {
+	t.Helper()
+	cfg, err := ServerConfig(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server-key.pem"), filepath.Join(dir, "ca.pem"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(h)
+	srv.TLS = cfg
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func clientConfig(t *testing.T, dir, client string) *tls.Config {
+	t.Helper()
+	var cert, key string
+	if client != "" {
+		cert, key = filepath.Join(dir, client+".pem"), filepath.Join(dir, client+"-key.pem")
+	}
+	cfg, err := ClientConfig(filepath.Join(dir, "ca.pem"), cert, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestMTLSHandshake(t *testing.T) {
+	dir := certDir(t)
+	srv := newMTLSServer(t, dir, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.TLS.PeerCertificates[0].Subject.CommonName)
+	}))
+
+	for _, tc := range []struct {
+		name    string
+		client  string
+		wantErr bool
+	}{
+		{name: "CA-signed client cert", client: "client"},
+		{name: "no client cert", wantErr: true},
+		{name: "client cert from another CA", client: "other", wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig(t, dir, tc.client)}}
+			defer c.CloseIdleConnections()
+			resp, err := c.Get(srv.URL)
+			if tc.wantErr {
+				if err == nil {
+					resp.Body.Close()
+					t.Fatal("handshake succeeded, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			b, _ := io.ReadAll(resp.Body)
+			if string(b) != "test-client" {
+				t.Errorf("server saw client %q", b)
+			}
+		})
+	}
+}
+
+// 服务端证书不是ca.pem签发的，客户端拒绝
+func TestClientConfigVerifiesServer(t *testing.T) {
+	dir := certDir(t)
+	cfg, err := ServerConfig(filepath.Join(dir, "other.pem"), filepath.Join(dir, "other-key.pem"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewUnstartedServer(http.NotFoundHandler())
+	srv.TLS = cfg
+	srv.StartTLS()
+	defer srv.Close()
+
+	c := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig(t, dir, "")}}
+	if resp, err := c.Get(srv.URL); err == nil {
+		resp.Body.Close()
+		t.Error("server certificate from an untrusted CA was accepted")
+	}
+}
+
+func TestConfigErrors(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.pem")
+	notPEM := filepath.Join(dir, "not.pem")
+	if err := os.WriteFile(notPEM, []byte("hello"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ServerConfig(missing, missing, ""); err == nil {
+		t.Error("ServerConfig with a missing certificate: want error")
+	}
+	if _, err := ClientConfig(missing, "", ""); err == nil {
+		t.Error("ClientConfig with a missing CA: want error")
+	}
+	if _, err := ClientConfig(notPEM, "", ""); err == nil {
+		t.Error("ClientConfig with a CA file without certificates: want error")
+	}
+}
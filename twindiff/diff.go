@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// 行首标记：= 相同，~ 不同，< 只有左边，> 只有右边
+const (
+	same      = "="
+	changed   = "~"
+	leftOnly  = "<"
+	rightOnly = ">"
+)
+
+// align 按Kind做最长公共子序列对齐，Span名字不同(如"GET"与"HTTP GET")也能对上，
+// 一边多出来的Span(如otelhttp的Server Span)单独列出
+func align(left, right []*span) [][2]*span {
+	key := func(s *span) string { return s.kind }
+	n, m := len(left), len(right)
+	lcs := make([][]int, n+1)
+	for i := range lcs {
+		lcs[i] = make([]int, m+1)
+	}
+	for i := n - 1; i >= 0; i-- {
+		for j := m - 1; j >= 0; j-- {
+			if key(left[i]) == key(right[j]) {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var pairs [][2]*span
+	i, j := 0, 0
+	for i < n && j < m {
+		switch {
+		case key(left[i]) == key(right[j]):
+			pairs = append(pairs, [2]*span{left[i], right[j]})
+			i, j = i+1, j+1
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			pairs = append(pairs, [2]*span{left[i], nil})
+			i++
+		default:
+			pairs = append(pairs, [2]*span{nil, right[j]})
+			j++
+		}
+	}
+	for ; i < n; i++ {
+		pairs = append(pairs, [2]*span{left[i], nil})
+	}
+	for ; j < m; j++ {
+		pairs = append(pairs, [2]*span{nil, right[j]})
+	}
+	return pairs
+}
+
+// render 输出左右两边的结构差异，返回不同的字段数
+// all为false时只输出不同的字段
+func render(w io.Writer, leftName, rightName string, left, right map[string][]*span, all bool) int {
+	roles := make(map[string]bool)
+	for r := range left {
+		roles[r] = true
+	}
+	for r := range right {
+		roles[r] = true
+	}
+	sortedRoles := make([]string, 0, len(roles))
+	for r := range roles {
+		sortedRoles = append(sortedRoles, r)
+	}
+	sort.Strings(sortedRoles)
+
+	diffs := 0
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	row := func(mark, field, l, r string) {
+		if mark == same && !all {
+			return
+		}
+		if mark != same {
+			diffs++
+		}
+		fmt.Fprintf(tw, "  %s\t%s\t%s\t%s\n", mark, field, l, r)
+	}
+
+	for _, role := range sortedRoles {
+		fmt.Fprintf(tw, "== %s\t\t%s\t%s\n", role, leftName, rightName)
+		for _, p := range align(left[role], right[role]) {
+			l, r := p[0], p[1]
+			fmt.Fprintf(tw, "\t\t%s\t%s\n", title(l), title(r))
+			switch {
+			case l == nil:
+				row(rightOnly, "span", "-", r.name)
+				continue
+			case r == nil:
+				row(leftOnly, "span", l.name, "-")
+				continue
+			}
+			compare(row, "name", l.name, r.name)
+			compare(row, "scope", l.scope, r.scope)
+			compare(row, "status", l.status, r.status)
+			keys := make(map[string]string)
+			for k, v := range l.attrs {
+				keys[k] = v
+			}
+			for k, v := range r.attrs {
+				keys[k] = v
+			}
+			for _, k := range sortedKeys(keys) {
+				lv, lok := l.attrs[k]
+				rv, rok := r.attrs[k]
+				switch {
+				case !lok:
+					row(rightOnly, k, "-", rv)
+				case !rok:
+					row(leftOnly, k, lv, "-")
+				default:
+					compare(row, k, lv, rv)
+				}
+			}
+			for i := 0; i < len(l.events) || i < len(r.events); i++ {
+				field := "event[" + strconv.Itoa(i) + "]"
+				switch {
+				case i >= len(l.events):
+					row(rightOnly, field, "-", r.events[i])
+				case i >= len(r.events):
+					row(leftOnly, field, l.events[i], "-")
+				default:
+					compare(row, field, l.events[i], r.events[i])
+				}
+			}
+		}
+		fmt.Fprintln(tw)
+	}
+	tw.Flush()
+	return diffs
+}
+
+func compare(row func(mark, field, l, r string), field, l, r string) {
+	if l == r {
+		row(same, field, l, r)
+	} else {
+		row(changed, field, l, r)
+	}
+}
+
+func title(s *span) string {
+	if s == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%s%s [%s]", strings.Repeat("  ", s.depth), s.name, s.kind)
+}
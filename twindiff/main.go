@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+)
+
+// 依次运行http-twin与http-twin-with-plugin，把两边的Trace发到内嵌Collector，输出结构差异
+// 升级otelhttp等依赖后，差异的变化一眼就能看出来；配合-golden可以作为检查
+// go run ./twindiff
+// go run ./twindiff -golden twindiff/testdata/http.golden [-update]
+//
+// 同样的检查在TestHTTPGolden里；TestSnapshots另外把每个场景(含grpc-twin与三跳的-downstream)的Trace
+// 整理成稳定的OTLP JSON，与testdata/<场景>.json比较，用来发现升级otelhttp/otelgrpc后埋点的变化
+// PLAYGROUND_TWINDIFF=1 go test ./twindiff [-update]
+func main() {
+	root := flag.String("root", ".", "repository root")
+	all := flag.Bool("all", false, "also print fields that are identical")
+	verbose := flag.Bool("v", false, "show output of the twins")
+	golden := flag.String("golden", "", "compare the diff with this golden file")
+	update := flag.Bool("update", false, "rewrite the golden file instead of comparing")
+	flag.Parse()
+
+	ctx := context.Background()
+	collector, err := otlp.NewCollector("127.0.0.1:0")
+	if err != nil {
+		fmt.Printf("启动Collector失败: %s\n", err)
+		os.Exit(1)
+	}
+	defer collector.Shutdown(ctx)
+
+	binDir, err := os.MkdirTemp("", "twindiff")
+	if err != nil {
+		fmt.Printf("创建临时目录失败: %s\n", err)
+		os.Exit(1)
+	}
+	defer os.RemoveAll(binDir)
+
+	r := &runner{root: *root, binDir: binDir, collector: collector, output: io.Discard}
+	if *verbose {
+		r.output = os.Stderr
+	}
+
+	results := make([]map[string][]*span, len(httpScenarios))
+	for i, sc := range httpScenarios {
+		rss, err := r.run(ctx, sc)
+		if err != nil {
+			fmt.Printf("运行%s失败: %s\n", sc.name, err)
+			os.Exit(1)
+		}
+		results[i] = shapes(rss, sc.services)
+	}
+
+	var out bytes.Buffer
+	diffs := render(&out, httpScenarios[0].name, httpScenarios[1].name, results[0], results[1], *all)
+	fmt.Fprintf(&out, "%d differing fields\n", diffs)
+	os.Stdout.Write(out.Bytes())
+
+	if *golden != "" {
+		if err := checkSnapshot(*golden, out.Bytes(), *update); err != nil {
+			fmt.Printf("\n%s\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// lineDiff 逐行列出不同，golden文件很小，不需要真正的diff算法
+func lineDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			fmt.Fprintf(&b, "%d:\n- %s\n+ %s\n", i+1, wl, gl)
+		}
+	}
+	return b.String()
+}
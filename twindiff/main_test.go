@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+)
+
+// 需要编译并运行各个Twin，占用固定端口3000/3001/8080/8081/9464-9469，耗时一分多钟
+// 默认跳过，设置runEnv或-update时才运行：
+// PLAYGROUND_TWINDIFF=1 go test ./twindiff [-update]
+var update = flag.Bool("update", false, "rewrite testdata instead of comparing")
+
+const runEnv = "PLAYGROUND_TWINDIFF"
+
+func newRunner(t *testing.T) *runner {
+	t.Helper()
+	if testing.Short() || (os.Getenv(runEnv) == "" && !*update) {
+		t.Skipf("builds and runs the twins on fixed ports; set %s=1 to run", runEnv)
+	}
+	collector, err := otlp.NewCollector("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = collector.Shutdown(context.Background()) })
+
+	r := &runner{root: "..", binDir: t.TempDir(), collector: collector, output: io.Discard}
+	if testing.Verbose() {
+		r.output = os.Stderr
+	}
+	return r
+}
+
+func TestHTTPGolden(t *testing.T) {
+	r := newRunner(t)
+	ctx := context.Background()
+
+	results := make([]map[string][]*span, len(httpScenarios))
+	for i, sc := range httpScenarios {
+		rss, err := r.run(ctx, sc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results[i] = shapes(rss, sc.services)
+	}
+
+	var out bytes.Buffer
+	diffs := render(&out, httpScenarios[0].name, httpScenarios[1].name, results[0], results[1], false)
+	fmt.Fprintf(&out, "%d differing fields\n", diffs)
+	if err := checkSnapshot("testdata/http.golden", out.Bytes(), *update); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSnapshots(t *testing.T) {
+	r := newRunner(t)
+	for _, sc := range scenarios {
+		t.Run(sc.name, func(t *testing.T) {
+			rss, err := r.run(context.Background(), sc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			b, err := snapshot(rss)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := checkSnapshot(filepath.Join("testdata", sc.name+".json"), b, *update); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
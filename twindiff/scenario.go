@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/auth"
+	"github.com/dextercai/OpenTelemetry-Golang-Playground/otlp"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// process 场景里的一个Twin进程
+type process struct {
+	pkg  string
+	args []string
+	addr string // Server监听的地址，可以连接后才启动下一个进程
+}
+
+// scenario 一组Server+Client，Server按顺序启动(下游在前)，Client发完请求后自行退出
+type scenario struct {
+	name     string
+	servers  []process
+	client   process
+	services map[string]string // service.name -> 角色
+}
+
+// 两个HTTP Server都监听:3000，所以场景只能依次运行
+const (
+	httpAddr = "127.0.0.1:3000"
+	grpcAddr = "127.0.0.1:8080"
+)
+
+// httpScenarios 用来比较手动埋点与otelhttp的两个场景
+var httpScenarios = []scenario{
+	{
+		name:     "manual",
+		servers:  []process{{pkg: "./http-twin/server", addr: httpAddr}},
+		client:   process{pkg: "./http-twin/client"},
+		services: map[string]string{"httpServer": "server", "httpClient": "client"},
+	},
+	{
+		name:     "plugin",
+		servers:  []process{{pkg: "./http-twin-with-plugin/server", addr: httpAddr}},
+		client:   process{pkg: "./http-twin-with-plugin/client"},
+		services: map[string]string{"httpServer-plugin": "server", "httpClient-plugin": "client"},
+	},
+}
+
+// scenarios 每个场景各有一份testdata/<name>.json快照
+var scenarios = append(httpScenarios[:len(httpScenarios):len(httpScenarios)],
+	scenario{
+		name:     "grpc",
+		servers:  []process{{pkg: "./grpc-twin/server", addr: grpcAddr}},
+		client:   process{pkg: "./grpc-twin/client", args: []string{"-addr", grpcAddr}},
+		services: map[string]string{"grpcServer": "server", "grpcClient": "client"},
+	},
+	// client -> httpServer -> grpcServer
+	scenario{
+		name: "downstream",
+		servers: []process{
+			{pkg: "./grpc-twin/server", addr: grpcAddr},
+			{pkg: "./http-twin/server", args: []string{"-downstream", grpcAddr}, addr: httpAddr},
+		},
+		client:   process{pkg: "./http-twin/client"},
+		services: map[string]string{"httpClient": "client", "httpServer": "server", "grpcServer": "downstream"},
+	},
+)
+
+type runner struct {
+	root      string // 仓库根目录，包路径相对于它
+	binDir    string
+	collector *otlp.Collector
+	output    io.Writer // Twin自身的输出
+}
+
+func (r *runner) build(ctx context.Context, pkg, name string) (string, error) {
+	bin := filepath.Join(r.binDir, name)
+	cmd := exec.CommandContext(ctx, "go", "build", "-o", bin, pkg)
+	cmd.Dir = r.root
+	cmd.Stdout, cmd.Stderr = r.output, r.output
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("building %s: %w", pkg, err)
+	}
+	return bin, nil
+}
+
+func (r *runner) command(ctx context.Context, bin string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Dir = r.root
+	// 固定ID种子与假时钟，同一个场景每次运行得到相同的Trace；开启认证，比较两边的enduser.id
+	cmd.Env = append(os.Environ(),
+		otlp.EndpointOverrideEnv+"="+r.collector.Endpoint(),
+		otlp.IDSeedEnv+"=1",
+		otlp.FakeClockEnv+"=2000-01-01T00:00:00Z",
+		auth.SecretEnv+"=twindiff",
+	)
+	cmd.Stdout, cmd.Stderr = r.output, r.output
+	return cmd
+}
+
+// run 运行一个场景，返回内嵌Collector在这期间收到的Span
+func (r *runner) run(ctx context.Context, sc scenario) ([]*tracepb.ResourceSpans, error) {
+	serverBins := make([]string, len(sc.servers))
+	for i, p := range sc.servers {
+		bin, err := r.build(ctx, p.pkg, fmt.Sprintf("%s-server%d", sc.name, i))
+		if err != nil {
+			return nil, err
+		}
+		serverBins[i] = bin
+	}
+	clientBin, err := r.build(ctx, sc.client.pkg, sc.name+"-client")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range sc.servers {
+		if conn, err := net.Dial("tcp", p.addr); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is already in use, stop the running twin first", p.addr)
+		}
+	}
+
+	r.collector.Reset()
+	for i, p := range sc.servers {
+		server := r.command(ctx, serverBins[i], p.args...)
+		if err := server.Start(); err != nil {
+			return nil, fmt.Errorf("starting %s server %s: %w", sc.name, p.pkg, err)
+		}
+		exited := make(chan error, 1)
+		go func() { exited <- server.Wait() }()
+		defer func() {
+			_ = server.Process.Kill()
+			<-exited
+		}()
+		if err := waitListening(ctx, p.addr, 30*time.Second, exited); err != nil {
+			return nil, fmt.Errorf("%s server %s: %w", sc.name, p.pkg, err)
+		}
+	}
+
+	if err := r.command(ctx, clientBin, sc.client.args...).Run(); err != nil {
+		return nil, fmt.Errorf("running %s client: %w", sc.name, err)
+	}
+
+	// Client退出前会等待自己的Span发完，Server的Span按BatchSpanProcessor的间隔发送，这里等到每个服务都有数据
+	deadline := time.Now().Add(15 * time.Second)
+	for !hasAllServices(r.collector.Spans(), sc.services) {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%s: timed out waiting for spans from %v", sc.name, sc.services)
+		}
+		time.Sleep(200 * time.Millisecond)
+	}
+	return r.collector.Spans(), nil
+}
+
+// waitListening 等待addr可以连接，进程提前退出时直接返回
+func waitListening(ctx context.Context, addr string, timeout time.Duration, exited chan error) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		conn, err := net.Dial("tcp", addr)
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("not listening on %s after %s", addr, timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case err := <-exited:
+			exited <- err
+			return fmt.Errorf("exited before listening on %s: %v", addr, err)
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+func hasAllServices(rss []*tracepb.ResourceSpans, services map[string]string) bool {
+	seen := make(map[string]bool)
+	for _, rs := range rss {
+		seen[serviceName(rs)] = true
+	}
+	for name := range services {
+		if !seen[name] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// span 去掉ID、时间戳后的Span结构，只保留两种埋点方式可以对比的部分
+type span struct {
+	role   string
+	depth  int
+	name   string
+	kind   string
+	scope  string
+	status string
+	attrs  map[string]string
+	events []string
+
+	id, parent string
+	start      uint64
+}
+
+// volatileAttrs 每次运行都会变化的属性，只比较是否存在
+var volatileAttrs = map[string]bool{
+	"net.sock.peer.port":    true,
+	"net.peer.port":         true,
+	"network.peer.port":     true,
+	"client.port":           true,
+	"deadline.remaining_ms": true, // 按真实时间计算，不受FakeClock影响
+}
+
+func serviceName(rs *tracepb.ResourceSpans) string {
+	for _, kv := range rs.GetResource().GetAttributes() {
+		if kv.Key == "service.name" {
+			return kv.Value.GetStringValue()
+		}
+	}
+	return ""
+}
+
+// shapes 把收到的Span按调用树的先序排列，再按角色分组
+func shapes(rss []*tracepb.ResourceSpans, services map[string]string) map[string][]*span {
+	var all []*span
+	byID := make(map[string]*span)
+	for _, rs := range rss {
+		role, ok := services[serviceName(rs)]
+		if !ok {
+			role = serviceName(rs)
+		}
+		for _, ss := range rs.ScopeSpans {
+			scope := ss.GetScope().GetName()
+			if v := ss.GetScope().GetVersion(); v != "" {
+				scope += "@" + v
+			}
+			for _, s := range ss.Spans {
+				sp := &span{
+					role:   role,
+					name:   s.Name,
+					kind:   strings.ToLower(strings.TrimPrefix(s.Kind.String(), "SPAN_KIND_")),
+					scope:  scope,
+					status: status(s.Status),
+					attrs:  attrs(s.Attributes),
+					id:     hex.EncodeToString(s.SpanId),
+					parent: hex.EncodeToString(s.ParentSpanId),
+					start:  s.StartTimeUnixNano,
+				}
+				for _, e := range s.Events {
+					sp.events = append(sp.events, event(e))
+				}
+				all = append(all, sp)
+				byID[sp.id] = sp
+			}
+		}
+	}
+
+	children := make(map[string][]*span)
+	var roots []*span
+	for _, sp := range all {
+		if _, ok := byID[sp.parent]; ok {
+			children[sp.parent] = append(children[sp.parent], sp)
+		} else {
+			roots = append(roots, sp)
+		}
+	}
+	byStart := func(ss []*span) {
+		sort.SliceStable(ss, func(i, j int) bool { return ss[i].start < ss[j].start })
+	}
+
+	out := make(map[string][]*span)
+	var walk func(sp *span, depth int)
+	walk = func(sp *span, depth int) {
+		sp.depth = depth
+		out[sp.role] = append(out[sp.role], sp)
+		byStart(children[sp.id])
+		for _, c := range children[sp.id] {
+			walk(c, depth+1)
+		}
+	}
+	byStart(roots)
+	for _, sp := range roots {
+		walk(sp, 0)
+	}
+	return out
+}
+
+func status(s *tracepb.Status) string {
+	code := strings.TrimPrefix(s.GetCode().String(), "STATUS_CODE_")
+	if s.GetMessage() != "" {
+		return code + ": " + s.GetMessage()
+	}
+	return code
+}
+
+func attrs(kvs []*commonpb.KeyValue) map[string]string {
+	out := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		if volatileAttrs[kv.Key] {
+			out[kv.Key] = "*"
+			continue
+		}
+		out[kv.Key] = value(kv.Value)
+	}
+	return out
+}
+
+// baggagePrefix Twin用Baggage.String()作为事件名，成员顺序取决于map遍历，比较前先排序
+const baggagePrefix = "baggage got:"
+
+func eventName(name string) string {
+	members, ok := strings.CutPrefix(name, baggagePrefix)
+	if !ok || members == "" {
+		return name
+	}
+	list := strings.Split(members, ",")
+	sort.Strings(list)
+	return baggagePrefix + strings.Join(list, ",")
+}
+
+func event(e *tracepb.Span_Event) string {
+	name := eventName(e.Name)
+	if len(e.Attributes) == 0 {
+		return name
+	}
+	a := attrs(e.Attributes)
+	keys := sortedKeys(a)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + a[k]
+	}
+	return name + " {" + strings.Join(pairs, ", ") + "}"
+}
+
+func value(v *commonpb.AnyValue) string {
+	switch x := v.GetValue().(type) {
+	case *commonpb.AnyValue_StringValue:
+		return strconv.Quote(x.StringValue)
+	case *commonpb.AnyValue_BoolValue:
+		return strconv.FormatBool(x.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return strconv.FormatInt(x.IntValue, 10)
+	case *commonpb.AnyValue_DoubleValue:
+		return strconv.FormatFloat(x.DoubleValue, 'g', -1, 64)
+	case *commonpb.AnyValue_ArrayValue:
+		vs := make([]string, len(x.ArrayValue.Values))
+		for i, e := range x.ArrayValue.Values {
+			vs[i] = value(e)
+		}
+		return "[" + strings.Join(vs, ", ") + "]"
+	case *commonpb.AnyValue_BytesValue:
+		return fmt.Sprintf("bytes(%d)", len(x.BytesValue))
+	case *commonpb.AnyValue_KvlistValue:
+		return "{" + strconv.Itoa(len(x.KvlistValue.Values)) + " keys}"
+	}
+	return ""
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
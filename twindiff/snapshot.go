@@ -0,0 +1,210 @@
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// snapshotResourceAttrs Resource上只保留与运行环境无关的属性，主机名、PID等每台机器都不同
+var snapshotResourceAttrs = map[string]bool{
+	"service.name":           true,
+	"telemetry.sdk.language": true,
+	"telemetry.sdk.name":     true,
+	"telemetry.sdk.version":  true,
+}
+
+// fakeEpoch 快照里的时间戳从这里开始，按原先的先后顺序每个相差1ms
+var fakeEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// snapshot 把一个场景收到的Span整理成稳定的OTLP JSON：
+// Resource/Scope/Span排序，TraceID、SpanID按出现顺序重新编号，时间戳换成假时钟，属性按key排序
+// Twin本身已用固定种子与FakeClock运行，这里再整理一次，使快照不依赖ID生成算法，也能合并两个进程各自的假时钟
+func snapshot(rss []*tracepb.ResourceSpans) ([]byte, error) {
+	req := &coltracepb.ExportTraceServiceRequest{}
+	for _, rs := range rss {
+		req.ResourceSpans = append(req.ResourceSpans, proto.Clone(rs).(*tracepb.ResourceSpans))
+	}
+
+	// 同一个服务可能分几批导出，先按服务、Scope合并
+	merged := make(map[string]*tracepb.ResourceSpans)
+	var order []string
+	for _, rs := range req.ResourceSpans {
+		name := serviceName(rs)
+		m, ok := merged[name]
+		if !ok {
+			m = &tracepb.ResourceSpans{Resource: rs.Resource, SchemaUrl: rs.SchemaUrl}
+			merged[name] = m
+			order = append(order, name)
+		}
+		for _, ss := range rs.ScopeSpans {
+			var target *tracepb.ScopeSpans
+			for _, t := range m.ScopeSpans {
+				if proto.Equal(t.Scope, ss.Scope) {
+					target = t
+				}
+			}
+			if target == nil {
+				target = &tracepb.ScopeSpans{Scope: ss.Scope, SchemaUrl: ss.SchemaUrl}
+				m.ScopeSpans = append(m.ScopeSpans, target)
+			}
+			target.Spans = append(target.Spans, ss.Spans...)
+		}
+	}
+	sort.Strings(order)
+	req.ResourceSpans = req.ResourceSpans[:0]
+	for _, name := range order {
+		req.ResourceSpans = append(req.ResourceSpans, merged[name])
+	}
+
+	var timestamps []uint64
+	for _, rs := range req.ResourceSpans {
+		var kept []*commonpb.KeyValue
+		for _, kv := range rs.Resource.GetAttributes() {
+			if snapshotResourceAttrs[kv.Key] {
+				kept = append(kept, kv)
+			}
+		}
+		if rs.Resource != nil {
+			rs.Resource.Attributes = sortAttrs(kept)
+		}
+		sort.SliceStable(rs.ScopeSpans, func(i, j int) bool {
+			return rs.ScopeSpans[i].GetScope().GetName() < rs.ScopeSpans[j].GetScope().GetName()
+		})
+		for _, ss := range rs.ScopeSpans {
+			sort.SliceStable(ss.Spans, func(i, j int) bool {
+				a, b := ss.Spans[i], ss.Spans[j]
+				if a.StartTimeUnixNano != b.StartTimeUnixNano {
+					return a.StartTimeUnixNano < b.StartTimeUnixNano
+				}
+				return a.Name < b.Name
+			})
+			for _, s := range ss.Spans {
+				s.Attributes = sortAttrs(normalizeAttrs(s.Attributes))
+				timestamps = append(timestamps, s.StartTimeUnixNano, s.EndTimeUnixNano)
+				for _, e := range s.Events {
+					e.Name = eventName(e.Name)
+					e.Attributes = sortAttrs(normalizeAttrs(e.Attributes))
+					timestamps = append(timestamps, e.TimeUnixNano)
+				}
+			}
+		}
+	}
+
+	fake := fakeClock(timestamps)
+	traceIDs := newIDMap(16)
+	spanIDs := newIDMap(8)
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				s.TraceId = traceIDs.get(s.TraceId)
+				s.SpanId = spanIDs.get(s.SpanId)
+				if len(s.ParentSpanId) > 0 {
+					s.ParentSpanId = spanIDs.get(s.ParentSpanId)
+				}
+				s.StartTimeUnixNano = fake[s.StartTimeUnixNano]
+				s.EndTimeUnixNano = fake[s.EndTimeUnixNano]
+				for _, e := range s.Events {
+					e.TimeUnixNano = fake[e.TimeUnixNano]
+				}
+				for _, l := range s.Links {
+					l.TraceId = traceIDs.get(l.TraceId)
+					l.SpanId = spanIDs.get(l.SpanId)
+				}
+			}
+		}
+	}
+
+	// protojson的输出故意带随机空白，先压缩再统一缩进
+	b, err := protojson.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+	var compact, out bytes.Buffer
+	if err := json.Compact(&compact, b); err != nil {
+		return nil, err
+	}
+	if err := json.Indent(&out, compact.Bytes(), "", "  "); err != nil {
+		return nil, err
+	}
+	out.WriteByte('\n')
+	return out.Bytes(), nil
+}
+
+func fakeClock(timestamps []uint64) map[uint64]uint64 {
+	sort.Slice(timestamps, func(i, j int) bool { return timestamps[i] < timestamps[j] })
+	out := map[uint64]uint64{0: 0}
+	next := uint64(fakeEpoch.UnixNano())
+	for _, t := range timestamps {
+		if _, ok := out[t]; ok {
+			continue
+		}
+		out[t] = next
+		next += uint64(time.Millisecond)
+	}
+	return out
+}
+
+// idMap 把原始ID按第一次出现的顺序映射成1、2、3...
+type idMap struct {
+	size int
+	ids  map[string][]byte
+}
+
+func newIDMap(size int) *idMap {
+	return &idMap{size: size, ids: make(map[string][]byte)}
+}
+
+func (m *idMap) get(id []byte) []byte {
+	key := hex.EncodeToString(id)
+	if v, ok := m.ids[key]; ok {
+		return v
+	}
+	v := make([]byte, m.size)
+	n := len(m.ids) + 1
+	for i := m.size - 1; i >= 0 && n > 0; i-- {
+		v[i] = byte(n)
+		n >>= 8
+	}
+	m.ids[key] = v
+	return v
+}
+
+func normalizeAttrs(kvs []*commonpb.KeyValue) []*commonpb.KeyValue {
+	for _, kv := range kvs {
+		if volatileAttrs[kv.Key] {
+			kv.Value = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "*"}}
+		}
+	}
+	return kvs
+}
+
+func sortAttrs(kvs []*commonpb.KeyValue) []*commonpb.KeyValue {
+	sort.SliceStable(kvs, func(i, j int) bool { return kvs[i].Key < kvs[j].Key })
+	return kvs
+}
+
+// checkSnapshot 与golden比较，update为true时改为写入
+func checkSnapshot(path string, got []byte, update bool) error {
+	if update {
+		return os.WriteFile(path, got, 0o644)
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(want, got) {
+		return fmt.Errorf("%s is out of date (rerun with -update after checking):\n%s", path, lineDiff(string(want), string(got)))
+	}
+	return nil
+}